@@ -0,0 +1,85 @@
+package ev
+
+import (
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ErrNonExistentWatch is returned by Backend.Remove when the directory is not being watched.
+var ErrNonExistentWatch = fsnotify.ErrNonExistentWatch
+
+// Backend is a source of raw file system notifications for an EventEmitter.
+// Backends report changes to the direct entries of each added directory; the EventEmitter
+// handles recursion by adding and removing subdirectories as they appear and disappear.
+type Backend interface {
+	// Add starts watching the entries of dir.
+	Add(dir string) error
+
+	// Remove stops watching the entries of dir.
+	Remove(dir string) error
+
+	// Events returns the channel that events are delivered on. Event info may be nil, in which
+	// case the EventEmitter resolves it from its cache or the file system.
+	Events() <-chan Event
+
+	// Errors returns the channel that non-fatal backend errors are delivered on.
+	Errors() <-chan error
+
+	// Close stops the backend and closes its channels.
+	Close() error
+}
+
+// FsnotifyBackend is the default Backend, driven by OS notifications (inotify, kqueue,
+// ReadDirectoryChangesW) via fsnotify.
+type FsnotifyBackend struct {
+	watcher *fsnotify.Watcher
+	events  chan Event
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewFsnotifyBackend returns a new FsnotifyBackend.
+func NewFsnotifyBackend() (*FsnotifyBackend, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &FsnotifyBackend{
+		watcher: watcher,
+		events:  make(chan Event),
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		defer close(b.events)
+		for fevent := range watcher.Events {
+			select {
+			case b.events <- NewEvent(Op(fevent.Op), fevent.Name, nil):
+			case <-b.done:
+				return
+			}
+		}
+	}()
+
+	return b, nil
+}
+
+// Add starts watching the entries of dir.
+func (b *FsnotifyBackend) Add(dir string) error { return b.watcher.Add(dir) }
+
+// Remove stops watching the entries of dir.
+func (b *FsnotifyBackend) Remove(dir string) error { return b.watcher.Remove(dir) }
+
+// Events returns the channel that events are delivered on.
+func (b *FsnotifyBackend) Events() <-chan Event { return b.events }
+
+// Errors returns the channel that fsnotify errors are delivered on.
+func (b *FsnotifyBackend) Errors() <-chan error { return b.watcher.Errors }
+
+// Close stops the underlying fsnotify watcher.
+func (b *FsnotifyBackend) Close() error {
+	b.once.Do(func() { close(b.done) })
+	return b.watcher.Close()
+}
@@ -43,6 +43,21 @@ func main() {
 		return
 	}
 
+	if os.Args[1] == "validate" {
+		f := flag.NewFlagSet("validate", flag.ContinueOnError)
+		path := f.String("config", "", "the path to the config file")
+
+		err := f.Parse(os.Args[2:])
+		if err != nil {
+			panic(err)
+		}
+
+		if !cli.RunValidate(*path) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	if strings.HasPrefix(os.Args[1], "-") {
 		cli.RunEavesdrop(ctx)
 		return
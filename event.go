@@ -35,7 +35,8 @@ func OpFromString(op string) Op {
 	}
 }
 
-// String returns the file operation as a string.
+// String returns the file operation as a string. Combined operations, such as those merged
+// into a batch, are joined with "|" (e.g. "CREATE|WRITE").
 func (o Op) String() string {
 	switch o {
 	case CHMOD:
@@ -48,9 +49,20 @@ func (o Op) String() string {
 		return "RENAME"
 	case WRITE:
 		return "WRITE"
-	default:
+	}
+
+	var names []string
+	for _, op := range []Op{CREATE, WRITE, REMOVE, RENAME, CHMOD} {
+		if o&op != 0 {
+			names = append(names, op.String())
+		}
+	}
+
+	if len(names) == 0 {
 		return "UNKNOWN"
 	}
+
+	return strings.Join(names, "|")
 }
 
 // Event represents a file system change notification.
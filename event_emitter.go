@@ -7,48 +7,64 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
-
-	"github.com/fsnotify/fsnotify"
 )
 
 // EventEmitter watches a directory tree for file system events and dispatches
 // them to registered Subscribers. Add watchers via Subscribe and call Start to begin.
 type EventEmitter struct {
 	root        string
-	cache       map[string]fs.FileInfo
+	cache       *fileCache
 	excluder    *Excluder
-	watcher     *fsnotify.Watcher
+	backend     Backend
 	subscribers []Subscriber
 	mu          sync.RWMutex
 }
 
-// NewEmitter returns a new EventEmitter rooted at root. Panics if root is empty.
+// NewEmitter returns a new EventEmitter rooted at root, backed by an FsnotifyBackend unless
+// another is given via WithBackend. The FsnotifyBackend is only created once the emitter starts
+// watching, so file systems without OS notifications can use a PollingBackend instead.
+// Panics if root is empty.
 func NewEmitter(root string) *EventEmitter {
 	if root == "" {
 		panic("root directory cannot be blank")
 	}
 
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		panic(err)
+	return &EventEmitter{
+		root:  root,
+		cache: newFileCache(),
+	}
+}
+
+// initBackend creates the default FsnotifyBackend if no backend was given.
+func (e *EventEmitter) initBackend() error {
+	if e.backend != nil {
+		return nil
 	}
 
-	return &EventEmitter{
-		root:    root,
-		cache:   make(map[string]fs.FileInfo),
-		watcher: watcher,
+	backend, err := NewFsnotifyBackend()
+	if err != nil {
+		return err
 	}
+	e.backend = backend
+
+	return nil
 }
 
 // Start begins watching the root directory tree and dispatching events to subscribers.
 // Newly created directories are watched automatically; removed directories are unwatched.
 // Stops when ctx is cancelled.
 func (e *EventEmitter) Start(ctx context.Context) {
+	if err := e.initBackend(); err != nil {
+		slog.Error("failed to create backend", slog.Any("error", err))
+		return
+	}
+
 	go func() {
 		<-ctx.Done()
 
-		err := e.watcher.Close()
+		err := e.backend.Close()
 		if err != nil {
 			slog.Error("EventManager.Run", slog.Any("error", err))
 		}
@@ -63,23 +79,31 @@ func (e *EventEmitter) Start(ctx context.Context) {
 	go func() {
 		for {
 			select {
-			case fevent, ok := <-e.watcher.Events:
+			case bevent, ok := <-e.backend.Events():
 				if !ok {
 					return
 				}
 
-				file, ok := e.cache[fevent.Name]
-				if !ok {
-					var err error
-					file, err = os.Stat(fevent.Name)
-					if err != nil {
-						// log nothing, this is noisy and usually as a result of temp files.
-						continue
+				file := bevent.Info()
+				if file == nil {
+					if file, ok = e.cache.get(bevent.Path()); !ok {
+						var err error
+						file, err = os.Stat(bevent.Path())
+						if err != nil {
+							// log nothing, this is noisy and usually as a result of temp files.
+							continue
+						}
 					}
-					e.cache[fevent.Name] = file
 				}
 
-				event := NewEvent(Op(fevent.Op), fevent.Name, file)
+				event := NewEvent(bevent.Op(), bevent.Path(), file)
+
+				// removed paths are dropped so that a PollingBackend does not report them again.
+				if event.Has(REMOVE) || event.Has(RENAME) {
+					e.cache.delete(event.Path())
+				} else {
+					e.cache.set(event.Path(), file)
+				}
 
 				if e.excluder != nil && e.excluder.ShouldIgnore(event) {
 					continue
@@ -104,7 +128,7 @@ func (e *EventEmitter) Start(ctx context.Context) {
 
 				e.publish(event)
 
-			case err, ok := <-e.watcher.Errors:
+			case err, ok := <-e.backend.Errors():
 				if !ok {
 					return
 				}
@@ -117,6 +141,10 @@ func (e *EventEmitter) Start(ctx context.Context) {
 // RecursiveWatch adds dir and all subdirectories to the watch list, populating the file cache.
 // Excluded directories (via WithExcluder) are skipped entirely.
 func (e *EventEmitter) RecursiveWatch(dir string) error {
+	if err := e.initBackend(); err != nil {
+		return err
+	}
+
 	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return nil
@@ -126,7 +154,7 @@ func (e *EventEmitter) RecursiveWatch(dir string) error {
 		if err != nil {
 			return nil
 		}
-		e.cache[path] = file
+		e.cache.set(path, file)
 
 		if !d.IsDir() {
 			return nil
@@ -136,7 +164,7 @@ func (e *EventEmitter) RecursiveWatch(dir string) error {
 			return fs.SkipDir
 		}
 
-		err = e.watcher.Add(path)
+		err = e.backend.Add(path)
 		if err != nil {
 			slog.Error("failed to watch", slog.String("path", path))
 			return nil
@@ -150,19 +178,23 @@ func (e *EventEmitter) RecursiveWatch(dir string) error {
 
 // RecursiveUnwatch removes dir and all subdirectories from the watch list and clears them from the cache.
 func (e *EventEmitter) RecursiveUnwatch(dir string) error {
+	if err := e.initBackend(); err != nil {
+		return err
+	}
+
 	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return nil
 		}
 
-		delete(e.cache, path)
+		e.cache.delete(path)
 
 		if !d.IsDir() {
 			return nil
 		}
 
-		err = e.watcher.Remove(path)
-		if err != nil && !errors.Is(err, fsnotify.ErrNonExistentWatch) {
+		err = e.backend.Remove(path)
+		if err != nil && !errors.Is(err, ErrNonExistentWatch) {
 			slog.Error("failed to unwatch", slog.String("path", path), slog.Any("error", err))
 		}
 
@@ -175,11 +207,21 @@ type Subscriber interface {
 	Handle(event Event)
 }
 
-// Subscribe registers a Subscriber to receive events. Must be called before Start.
+// Subscribe registers a Subscriber to receive events. It may be called before or after Start.
 func (e *EventEmitter) Subscribe(subscriber Subscriber) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.subscribers = append(e.subscribers, subscriber)
 }
 
+// Unsubscribe stops delivering events to subscriber. Events already being delivered may still
+// reach it.
+func (e *EventEmitter) Unsubscribe(subscriber Subscriber) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.subscribers = slices.DeleteFunc(e.subscribers, func(s Subscriber) bool { return s == subscriber })
+}
+
 func (e *EventEmitter) publish(event Event) {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
@@ -189,6 +231,22 @@ func (e *EventEmitter) publish(event Event) {
 	}
 }
 
+// WithBackend replaces the default FsnotifyBackend, e.g. with a PollingBackend on file systems
+// where OS notifications are unreliable. Must be called before Start.
+func (e *EventEmitter) WithBackend(backend Backend) *EventEmitter {
+	if e.backend != nil {
+		err := e.backend.Close()
+		if err != nil {
+			slog.Error("failed to close backend", slog.Any("error", err))
+		}
+	}
+	if b, ok := backend.(interface{ useCache(*fileCache) }); ok {
+		b.useCache(e.cache)
+	}
+	e.backend = backend
+	return e
+}
+
 // WithExcluder attaches an Excluder that filters events and directories before they are watched or dispatched.
 func (e *EventEmitter) WithExcluder(excluder *Excluder) *EventEmitter {
 	e.excluder = excluder
@@ -62,6 +62,13 @@ func TestEventEmitter_WithExcluder_Chainable(t *testing.T) {
 	}
 }
 
+func TestEventEmitter_WithBackend_Chainable(t *testing.T) {
+	e := ev.NewEmitter(t.TempDir())
+	if got := e.WithBackend(ev.NewPollingBackend(pollInterval)); got != e {
+		t.Error("WithBackend() did not return same *EventEmitter")
+	}
+}
+
 func TestEventEmitter_RecursiveWatch(t *testing.T) {
 	dir := t.TempDir()
 	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
@@ -174,3 +181,33 @@ func TestEventEmitter_Start_ExcluderFiltersEvents(t *testing.T) {
 		}
 	}
 }
+
+func TestEventEmitter_Unsubscribe(t *testing.T) {
+	dir := t.TempDir()
+
+	kept, keptCh := testWatcher(t, dir)
+	removed := ev.NewWatcher(t.Name()+"/removed", dir).
+		WithFiletypes(".go").
+		WithDebounceDelay(debounceDelay).
+		WithExcluder(ev.NewExcluder(dir))
+
+	removedCh := make(chan ev.Event, 16)
+	removed.WithOnChange(func(e ev.Event) { removedCh <- e })
+
+	e := ev.NewEmitter(dir)
+	e.Subscribe(kept)
+	e.Subscribe(removed)
+	e.Unsubscribe(removed)
+	e.Start(t.Context())
+
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	awaitEvent(t, keptCh)
+
+	select {
+	case <-removedCh:
+		t.Error("unsubscribed watcher received an event")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
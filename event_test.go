@@ -27,6 +27,7 @@ func TestOp_String(t *testing.T) {
 		{ev.REMOVE, "REMOVE"},
 		{ev.RENAME, "RENAME"},
 		{ev.WRITE, "WRITE"},
+		{ev.CREATE | ev.WRITE, "CREATE|WRITE"},
 		{ev.Op(0), "UNKNOWN"},
 	}
 
@@ -1,19 +1,23 @@
 package ev
 
 import (
+	"log/slog"
 	"path/filepath"
 	"regexp"
 	"strings"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/dimmerz92/eavesdrop/v2/internal/components"
 )
 
 type Excluder struct {
-	root  string
-	ops   Op
-	dirs  components.Set[string]
-	files components.Set[string]
-	regex []*regexp.Regexp
+	root      string
+	ops       Op
+	dirs      components.Set[string]
+	files     components.Set[string]
+	regex     []*regexp.Regexp
+	globs     []string
+	gitignore *components.Gitignore
 }
 
 // NewExcluder returns a new Excluder rooted at at the given root.
@@ -55,6 +59,23 @@ func (e *Excluder) ShouldIgnore(event Event) bool {
 		}
 	}
 
+	if len(e.globs) > 0 || e.gitignore != nil {
+		rel, err := filepath.Rel(e.root, cleanPath)
+		if err != nil {
+			return false
+		}
+		rel = filepath.ToSlash(rel)
+
+		if matchGlobs(e.globs, rel) {
+			return true
+		}
+
+		isDir := event.info != nil && event.info.IsDir()
+		if e.gitignore != nil && e.gitignore.Match(rel, isDir) {
+			return true
+		}
+	}
+
 	return false
 }
 
@@ -92,3 +113,43 @@ func (e *Excluder) WithRegex(regex ...string) *Excluder {
 	}
 	return e
 }
+
+// WithGlobs adds doublestar glob patterns matched against paths relative to the excluder root,
+// e.g. "**/*_templ.go" or "**/testdata/**". Panics if a pattern is invalid.
+func (e *Excluder) WithGlobs(globs ...string) *Excluder {
+	e.globs = append(e.globs, mustValidateGlobs(globs)...)
+	return e
+}
+
+// WithGitignore excludes paths ignored by git: those matched by .git/info/exclude and every
+// .gitignore file under the excluder root, with full gitignore semantics including negation,
+// anchoring, and "**". Paths inside .git are always excluded. Ignore files are read once, when
+// this is called; unreadable files are logged and skipped.
+func (e *Excluder) WithGitignore() *Excluder {
+	gitignore, err := components.LoadGitignore(e.root)
+	if err != nil {
+		slog.Warn("failed to read gitignore", slog.String("root", e.root), slog.Any("error", err))
+	}
+	e.gitignore = gitignore
+	return e
+}
+
+// mustValidateGlobs returns globs, panicking if any is not a valid doublestar pattern.
+func mustValidateGlobs(globs []string) []string {
+	for _, glob := range globs {
+		if !doublestar.ValidatePattern(glob) {
+			panic("invalid glob pattern: " + glob)
+		}
+	}
+	return globs
+}
+
+// matchGlobs reports whether the slash-separated path matches any of globs.
+func matchGlobs(globs []string, path string) bool {
+	for _, glob := range globs {
+		if ok, _ := doublestar.Match(glob, path); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package ev_test
 
 import (
 	"io/fs"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
@@ -121,6 +122,30 @@ func TestExcluder_ShouldIgnore(t *testing.T) {
 			event:    ev.NewEvent(ev.WRITE, "main.go", mockFileInfo{}),
 			expected: false,
 		},
+		{
+			name:     "glob matches at any depth",
+			excluder: ev.NewExcluder(root).WithGlobs("**/*_templ.go"),
+			event:    ev.NewEvent(ev.WRITE, filepath.Join(root, "views", "home_templ.go"), mockFileInfo{}),
+			expected: true,
+		},
+		{
+			name:     "glob matches inside dir at any depth",
+			excluder: ev.NewExcluder(root).WithGlobs("**/testdata/**"),
+			event:    ev.NewEvent(ev.WRITE, filepath.Join(root, "pkg", "testdata", "case.txt"), mockFileInfo{}),
+			expected: true,
+		},
+		{
+			name:     "glob does not match path",
+			excluder: ev.NewExcluder(root).WithGlobs("**/*_templ.go"),
+			event:    ev.NewEvent(ev.WRITE, otherFile, mockFileInfo{}),
+			expected: false,
+		},
+		{
+			name:     "relative glob matched",
+			excluder: ev.NewExcluder(".").WithGlobs("vendor/*"),
+			event:    ev.NewEvent(ev.CREATE, filepath.Join("vendor", "pkg"), mockDirInfo{}),
+			expected: true,
+		},
 		{
 			name:     "relative excluded file not matched in subdirectory",
 			excluder: ev.NewExcluder(".").WithFiles("go.sum"),
@@ -137,3 +162,44 @@ func TestExcluder_ShouldIgnore(t *testing.T) {
 		})
 	}
 }
+
+func TestExcluder_WithGitignore(t *testing.T) {
+	root := t.TempDir()
+	err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("*.log\n!keep.log\nbin/\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	excluder := ev.NewExcluder(root).WithGitignore()
+
+	tests := []struct {
+		name     string
+		event    ev.Event
+		expected bool
+	}{
+		{"ignored file", ev.NewEvent(ev.WRITE, filepath.Join(root, "debug.log"), mockFileInfo{}), true},
+		{"negated file", ev.NewEvent(ev.WRITE, filepath.Join(root, "keep.log"), mockFileInfo{}), false},
+		{"ignored dir", ev.NewEvent(ev.CREATE, filepath.Join(root, "bin"), mockDirInfo{}), true},
+		{"file in ignored dir", ev.NewEvent(ev.WRITE, filepath.Join(root, "bin", "app"), mockFileInfo{}), true},
+		{"git dir", ev.NewEvent(ev.WRITE, filepath.Join(root, ".git", "index"), mockFileInfo{}), true},
+		{"unignored file", ev.NewEvent(ev.WRITE, filepath.Join(root, "main.go"), mockFileInfo{}), false},
+		{"root", ev.NewEvent(ev.WRITE, root, mockDirInfo{}), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := excluder.ShouldIgnore(test.event); got != test.expected {
+				t.Errorf("ShouldIgnore() = %v, expected %v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestExcluder_WithGlobs_PanicsOnInvalidPattern(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected panic on invalid glob")
+		}
+	}()
+	ev.NewExcluder(".").WithGlobs("[unclosed")
+}
@@ -0,0 +1,59 @@
+package ev
+
+import (
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"sync"
+)
+
+// fileCache holds the last known FileInfo of each path, grouped by parent directory so that a
+// PollingBackend can diff a directory's entries against a fresh listing. Safe for concurrent use.
+type fileCache struct {
+	dirs map[string]map[string]fs.FileInfo
+	mu   sync.RWMutex
+}
+
+func newFileCache() *fileCache {
+	return &fileCache{dirs: make(map[string]map[string]fs.FileInfo)}
+}
+
+func (c *fileCache) get(path string) (fs.FileInfo, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	info, ok := c.dirs[filepath.Dir(path)][path]
+	return info, ok
+}
+
+func (c *fileCache) set(path string, info fs.FileInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dir := filepath.Dir(path)
+	if c.dirs[dir] == nil {
+		c.dirs[dir] = make(map[string]fs.FileInfo)
+	}
+	c.dirs[dir][path] = info
+}
+
+// delete removes path and, if it is a directory, its entries.
+func (c *fileCache) delete(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.dirs[filepath.Dir(path)], path)
+	delete(c.dirs, path)
+}
+
+// entries returns a copy of the cached entries of dir.
+func (c *fileCache) entries(dir string) map[string]fs.FileInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return maps.Clone(c.dirs[dir])
+}
+
+// setEntries replaces the cached entries of dir.
+func (c *fileCache) setEntries(dir string, entries map[string]fs.FileInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dirs[dir] = entries
+}
@@ -2,26 +2,49 @@ package cli
 
 import (
 	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
 	"sync"
 
 	"github.com/dimmerz92/eavesdrop/v2"
 	"github.com/dimmerz92/eavesdrop/v2/internal/components"
 	"github.com/dimmerz92/eavesdrop/v2/internal/config"
+	"github.com/fatih/color"
 )
 
-func ConstructEventEmitter(ctx context.Context, config config.Config) *ev.EventEmitter {
-	ops := make([]ev.Op, 0, len(config.GlobalExclude.Ops))
-	for _, op := range config.GlobalExclude.Ops {
-		ops = append(ops, ev.OpFromString(op))
+// palette is the set of colors that watcher output prefixes are chosen from.
+var palette = []color.Attribute{color.FgCyan, color.FgBlue, color.FgMagenta, color.FgGreen, color.FgYellow}
+
+func ConstructEventEmitter(ctx context.Context, cfg config.Config) (*ev.EventEmitter, error) {
+	emitter := ev.NewEmitter(cfg.RootDir).
+		WithExcluder(ConstructExcluder(cfg.RootDir, cfg.GlobalExclude))
+
+	if cfg.Backend == config.BackendPoll {
+		backend, err := ConstructBackend(cfg)
+		if err != nil {
+			return nil, err
+		}
+		emitter.WithBackend(backend)
 	}
 
-	return ev.NewEmitter(config.RootDir).
-		WithExcluder(ev.NewExcluder(config.RootDir).
-			WithOps(ops...).
-			WithDirs(config.GlobalExclude.Dirs...).
-			WithFiles(config.GlobalExclude.Files...).
-			WithRegex(config.GlobalExclude.Regex...),
-		)
+	return emitter, nil
+}
+
+// ConstructBackend returns the file system backend selected by the config.
+func ConstructBackend(cfg config.Config) (ev.Backend, error) {
+	switch cfg.Backend {
+	case config.BackendFsnotify, "":
+		return ev.NewFsnotifyBackend()
+	case config.BackendPoll:
+		return ev.NewPollingBackend(cfg.PollInterval), nil
+	default:
+		return nil, fmt.Errorf("unknown backend: %s", cfg.Backend)
+	}
 }
 
 func ConstructProxy(ctx context.Context, config config.ProxyConfig) (ev.Proxy, error) {
@@ -29,7 +52,7 @@ func ConstructProxy(ctx context.Context, config config.ProxyConfig) (ev.Proxy, e
 		return nil, nil
 	}
 
-	proxy, err := components.NewProxy(ctx, config.AppPort, config.ProxyPort)
+	proxy, err := components.NewProxy(ctx, config.AppPort.Uint16(), config.ProxyPort.Uint16())
 	if err != nil {
 		return nil, err
 	}
@@ -40,30 +63,198 @@ func ConstructProxy(ctx context.Context, config config.ProxyConfig) (ev.Proxy, e
 func ConstructWatcher(
 	ctx context.Context,
 	root string,
+	env []string,
 	mu *sync.Mutex,
 	proxy ev.Proxy,
 	config config.WatcherConfig,
-) *ev.Watcher {
-	shell := ev.NewShell(ctx, config.Shell.TaskTimeout, config.Shell.ServiceShutdownTimeout)
+	done func(succeeded bool),
+) (*ev.Watcher, []Service, error) {
+	opts := ConstructShellOptions(root, env, config.Shell)
 
-	onChange := NewShellRunner(shell, config.Name, mu, config.Shell.Tasks, config.Shell.Service)
+	log, err := ConstructLog(ctx, root, config.Output)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", config.Name, err)
+	}
 
-	ops := make([]ev.Op, 0, len(config.Exclude.Ops))
-	for _, op := range config.Exclude.Ops {
-		ops = append(ops, ev.OpFromString(op))
+	shell := ev.NewShell(ctx, config.Shell.TaskTimeout, config.Shell.ServiceShutdownTimeout, opts...).
+		WithOutput(ConstructOutput(config.Name, config.Output, log))
+
+	// Each task gets its own line buffers, copying its output to its capture and the log file.
+	output := func(capture io.Writer) (io.Writer, io.Writer) {
+		return ConstructOutput(config.Name, config.Output, io.MultiWriter(capture, log))
 	}
 
-	return ev.NewWatcher(config.Name, root).
+	services := ConstructServices(ctx, config, opts, log)
+
+	shells := make([]*ev.Shell, len(services))
+	for i, service := range services {
+		shells[i] = service.Shell
+	}
+
+	probes, err := ConstructProbes(config.Shell.Readiness, shells...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", config.Name, err)
+	}
+
+	overlay, _ := proxy.(Overlay)
+
+	onBatch := NewShellRunner(shell, config.Name, mu, config.RunMode, config.Shell.Tasks, services, overlay, output, done)
+
+	watcher := ev.NewWatcher(config.Name, root).
 		WithFiletypes(config.Filetypes...).
 		WithDirs(config.Dirs...).
 		WithFiles(config.Files...).
-		WithOnChange(onChange).
+		WithGlobs(config.Globs...).
+		WithOnBatchResult(onBatch).
 		WithProxy(proxy, config.RefreshDelay).
+		WithCSSRefresh(config.CSSFiletypes()...).
+		WithReadiness(config.Shell.Readiness.Timeout, probes...).
 		WithDebounceDelay(config.Shell.DebounceDelay).
-		WithExcluder(ev.NewExcluder(root).
-			WithOps(ops...).
-			WithDirs(config.Exclude.Dirs...).
-			WithFiles(config.Exclude.Files...).
-			WithRegex(config.Exclude.Regex...),
-		)
+		WithExcluder(ConstructExcluder(root, config.Exclude))
+
+	return watcher, services, nil
+}
+
+// ConstructServices returns a watcher's services, each with its own Shell, and so its own process
+// group, restart policy, and shutdown timeout. Output is prefixed with the watcher and service
+// names, and written to tee alongside the watcher's tasks.
+func ConstructServices(
+	ctx context.Context,
+	config config.WatcherConfig,
+	opts []ev.ShellOption,
+	tee io.Writer,
+) []Service {
+	var services []Service
+
+	for _, service := range config.Shell.AllServices() {
+		name := config.Name
+		if service.Name != "" {
+			name += "/" + service.Name
+		}
+
+		shell := ev.NewShell(ctx, config.Shell.TaskTimeout, service.ShutdownTimeout, opts...).
+			WithOutput(ConstructOutput(name, config.Output, tee)).
+			WithRestartPolicy(
+				ev.RestartPolicyFromString(config.Shell.Restart.Policy),
+				config.Shell.Restart.MaxRestarts,
+				config.Shell.Restart.Backoff,
+				config.Shell.Restart.MaxBackoff,
+			)
+
+		services = append(services, Service{Name: name, Command: service.Command, Shell: shell})
+	}
+
+	return services
+}
+
+// ConstructShellOptions returns the options for a watcher's Shell. Variables from env files are
+// overridden by the watcher's own env, and a relative cwd is resolved against root.
+func ConstructShellOptions(root string, env []string, config config.ShellConfig) []ev.ShellOption {
+	opts := []ev.ShellOption{ev.WithShellEnv(slices.Concat(env, config.Environ())...)}
+
+	if config.Shell.Path != "" {
+		opts = append(opts, ev.WithInterpreter(config.Shell.Path, config.Shell.Flag))
+	}
+
+	if config.Cwd != "" {
+		dir := config.Cwd
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(root, dir)
+		}
+		opts = append(opts, ev.WithWorkingDir(dir))
+	}
+
+	return opts
+}
+
+// ConstructExcluder returns an Excluder rooted at root applying the configured rules.
+func ConstructExcluder(root string, config config.ExcluderConfig) *ev.Excluder {
+	ops := make([]ev.Op, 0, len(config.Ops))
+	for _, op := range config.Ops {
+		ops = append(ops, ev.OpFromString(op))
+	}
+
+	excluder := ev.NewExcluder(root).
+		WithOps(ops...).
+		WithDirs(config.Dirs...).
+		WithFiles(config.Files...).
+		WithRegex(config.Regex...).
+		WithGlobs(config.Globs...)
+
+	if config.Gitignore {
+		excluder.WithGitignore()
+	}
+
+	return excluder
+}
+
+// ConstructOutput returns the stdout and stderr writers for a shell, prefixing each line with
+// the colored name and teeing both streams, unprefixed, to tee.
+func ConstructOutput(name string, config config.OutputConfig, tee io.Writer) (io.Writer, io.Writer) {
+	prefix := ""
+	if config.Prefix {
+		hash := fnv.New32a()
+		hash.Write([]byte(name))
+		prefix = color.New(palette[hash.Sum32()%uint32(len(palette))]).Sprintf("[%s] ", name)
+	}
+
+	stdout := components.NewPrefixWriter(os.Stdout, prefix).WithTee(tee)
+	stderr := components.NewPrefixWriter(os.Stderr, prefix).WithTee(tee)
+
+	return stdout, stderr
+}
+
+// ConstructLog returns a writer for a watcher's unprefixed output: a rotating log file that is
+// closed when ctx is done, or io.Discard if no log file is configured.
+func ConstructLog(ctx context.Context, root string, config config.OutputConfig) (io.Writer, error) {
+	if config.LogFile == "" {
+		return io.Discard, nil
+	}
+
+	path := config.LogFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+
+	file, err := components.NewRotatingFile(path, config.LogMaxSize, config.LogMaxFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		err := file.Close()
+		if err != nil {
+			slog.Error("failed to close log file", slog.String("path", path), slog.Any("error", err))
+		}
+	}()
+
+	return file, nil
+}
+
+// ConstructProbes returns the readiness probes configured for a watcher, attaching any log
+// probe to the output of every service shell.
+func ConstructProbes(config config.ReadinessConfig, services ...*ev.Shell) ([]ev.Probe, error) {
+	var probes []ev.Probe
+
+	if config.TCP != "" {
+		probes = append(probes, ev.NewTCPProbe(config.TCP, config.Interval))
+	}
+
+	if config.HTTP != "" {
+		probes = append(probes, ev.NewHTTPProbe(config.HTTP, config.Interval))
+	}
+
+	if config.Log != "" {
+		probe, err := ev.NewLogProbe(config.Log)
+		if err != nil {
+			return nil, fmt.Errorf("invalid readiness log pattern: %w", err)
+		}
+		for _, shell := range services {
+			shell.WithServiceOutput(probe)
+		}
+		probes = append(probes, probe)
+	}
+
+	return probes, nil
 }
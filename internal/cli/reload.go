@@ -0,0 +1,299 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/dimmerz92/eavesdrop/v2"
+	"github.com/dimmerz92/eavesdrop/v2/internal/components"
+	"github.com/dimmerz92/eavesdrop/v2/internal/config"
+	"github.com/fatih/color"
+)
+
+// runningWatcher is a watcher subscribed to the emitter, with the services it started.
+type runningWatcher struct {
+	config   config.WatcherConfig
+	env      []string // variables loaded from env_file, passed to every task and service
+	watcher  *ev.Watcher
+	services []Service
+	cancel   context.CancelFunc
+}
+
+// watcherSet manages the watchers subscribed to a shared EventEmitter, allowing them to be
+// replaced when the config file changes without restarting the emitter or proxy.
+type watcherSet struct {
+	ctx     context.Context
+	emitter *ev.EventEmitter
+	proxy   ev.Proxy
+
+	mu     sync.Mutex
+	config config.Config
+	locks  map[string]*sync.Mutex // serialise task runs, keyed by lock scope
+
+	runningMu sync.RWMutex // guards running, which triggers read while a reload is applied
+	running   map[string]*runningWatcher
+}
+
+func newWatcherSet(ctx context.Context, emitter *ev.EventEmitter, proxy ev.Proxy, cfg config.Config) *watcherSet {
+	return &watcherSet{
+		ctx:     ctx,
+		emitter: emitter,
+		proxy:   proxy,
+		config:  cfg,
+		running: make(map[string]*runningWatcher),
+		locks:   make(map[string]*sync.Mutex),
+	}
+}
+
+// lock returns the mutex serialising the watcher's task runs: one shared by every global
+// watcher, one of the watcher's own, or one shared by the watchers in the same lock group.
+// Locks outlive the watchers using them, so a reloaded watcher waits for its old runs.
+func (s *watcherSet) lock(watcherConfig config.WatcherConfig) *sync.Mutex {
+	var key string
+	switch watcherConfig.Lock {
+	case "", config.LockGlobal:
+		key = config.LockGlobal
+	case config.LockWatcher:
+		key = "watcher/" + watcherConfig.Name
+	default:
+		key = "group/" + watcherConfig.Lock
+	}
+
+	mu, ok := s.locks[key]
+	if !ok {
+		mu = &sync.Mutex{}
+		s.locks[key] = mu
+	}
+	return mu
+}
+
+// apply stops the running watchers that were removed or changed in cfg, including by a change
+// to the variables loaded from its env files, then starts the watchers that are new or changed.
+// Unchanged watchers keep running undisturbed. If a watcher fails to start, the watchers started
+// so far are stopped and the previous ones restored, so the running watchers are left as they were.
+func (s *watcherSet) apply(cfg config.Config) error {
+	env := cfg.Environ()
+
+	wanted := make(map[string]config.WatcherConfig, len(cfg.Watchers))
+	for _, watcherConfig := range cfg.Watchers {
+		wanted[watcherConfig.Name] = watcherConfig
+	}
+
+	var stopped []*runningWatcher
+	var started []string
+
+	for name, running := range s.running {
+		next, ok := wanted[name]
+		if ok && reflect.DeepEqual(next, running.config) && slices.Equal(env, running.env) {
+			continue
+		}
+		s.stop(name)
+		stopped = append(stopped, running)
+	}
+
+	for _, watcherConfig := range cfg.Watchers {
+		if _, ok := s.running[watcherConfig.Name]; ok {
+			continue
+		}
+
+		if err := s.start(watcherConfig, env); err != nil {
+			s.restore(started, stopped)
+			return err
+		}
+		started = append(started, watcherConfig.Name)
+	}
+
+	return nil
+}
+
+// restore stops the started watchers and starts the stopped ones again.
+func (s *watcherSet) restore(started []string, stopped []*runningWatcher) {
+	for _, name := range started {
+		s.stop(name)
+	}
+
+	for _, running := range stopped {
+		if err := s.start(running.config, running.env); err != nil {
+			color.Red("%s: failed to restore watcher: %v", running.config.Name, err)
+		}
+	}
+}
+
+// start constructs the watcher, with env added to its environment, and subscribes it to the
+// emitter, triggering it if it runs on start.
+func (s *watcherSet) start(watcherConfig config.WatcherConfig, env []string) error {
+	ctx, cancel := context.WithCancel(s.ctx)
+	watcher, services, err := ConstructWatcher(
+		ctx,
+		s.config.RootDir,
+		env,
+		s.lock(watcherConfig),
+		s.proxy,
+		watcherConfig,
+		func(succeeded bool) { s.triggerNext(watcherConfig, succeeded) },
+	)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	if watcherConfig.IgnoreOwnChanges {
+		watcher.WithIgnoreOwnChanges(s.ownChangesGrace(watcherConfig))
+	}
+
+	s.runningMu.Lock()
+	s.running[watcherConfig.Name] = &runningWatcher{
+		config:   watcherConfig,
+		env:      env,
+		watcher:  watcher,
+		services: services,
+		cancel:   cancel,
+	}
+	s.runningMu.Unlock()
+
+	s.emitter.Subscribe(watcher)
+	if watcherConfig.RunOnStart {
+		watcher.Trigger()
+	}
+
+	return nil
+}
+
+// ownChangesGrace returns how long after a run changes are still attributed to it: the debounce
+// delay, or a polling interval if longer, as the poller reports a run's last writes on its next pass.
+func (s *watcherSet) ownChangesGrace(watcherConfig config.WatcherConfig) uint {
+	grace := watcherConfig.Shell.DebounceDelay
+	if s.config.Backend == config.BackendPoll {
+		grace = max(grace, s.config.PollInterval)
+	}
+	return grace
+}
+
+// triggerNext triggers the watchers named by the watcher's on_success or on_failure, depending on
+// whether its run succeeded. Each runs in its own goroutine, so it waits for the lock it needs
+// without holding up the watcher that triggered it.
+func (s *watcherSet) triggerNext(watcherConfig config.WatcherConfig, succeeded bool) {
+	names := watcherConfig.OnFailure
+	if succeeded {
+		names = watcherConfig.OnSuccess
+	}
+
+	s.runningMu.RLock()
+	defer s.runningMu.RUnlock()
+
+	for _, name := range names {
+		next, ok := s.running[name]
+		if !ok {
+			color.Yellow("%s: cannot trigger %s: no such watcher", watcherConfig.Name, name)
+			continue
+		}
+
+		fmt.Printf("%s: triggering watcher: %s\n", color.CyanString(watcherConfig.Name), name)
+		go next.watcher.Trigger()
+	}
+}
+
+// stop unsubscribes the watcher called name, stops its services, and releases its name for reuse.
+func (s *watcherSet) stop(name string) {
+	s.runningMu.Lock()
+	running := s.running[name]
+	delete(s.running, name)
+	s.runningMu.Unlock()
+
+	s.emitter.Unsubscribe(running.watcher)
+	running.watcher.Close()
+
+	if err := StopServices(running.services); err != nil {
+		slog.Error("stopping services", slog.String("watcher", running.config.Name), slog.Any("error", err))
+	}
+	running.cancel()
+
+	if overlay, ok := s.proxy.(Overlay); ok {
+		overlay.ClearError(running.config.Name)
+	}
+}
+
+// reload reads the config file at path and applies its watchers. An invalid config is reported
+// and ignored, leaving the current watchers running.
+func (s *watcherSet) reload(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next, ok := loadConfig(path)
+	if !ok {
+		color.Red("config not reloaded")
+		return
+	}
+
+	current, candidate := s.config, next
+	current.Watchers, candidate.Watchers = nil, nil
+	current.EnvFile, candidate.EnvFile = nil, nil
+	current.Env, candidate.Env = nil, nil
+	if !reflect.DeepEqual(current, candidate) {
+		color.Yellow("only watcher and env_file changes are applied on reload; restart eavesdrop to apply other settings")
+	}
+
+	if err := s.apply(next); err != nil {
+		color.Red("config reload: %v", err)
+		color.Red("config not reloaded")
+		return
+	}
+
+	s.config.Watchers, s.config.EnvFile, s.config.Env = next.Watchers, next.EnvFile, next.Env
+	color.Green("config reloaded")
+}
+
+// watchConfig calls onChange whenever the config file at path is written, created, or replaced.
+// Editors often save by renaming over the file, so its directory is watched rather than the file.
+func watchConfig(ctx context.Context, cfg config.Config, path string, onChange func()) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	backend, err := ConstructBackend(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := backend.Add(filepath.Dir(path)); err != nil {
+		backend.Close()
+		return err
+	}
+
+	debouncer := components.NewDebouncer(config.DefaultDebounceDelay)
+
+	go func() {
+		defer backend.Close()
+		defer debouncer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case event, ok := <-backend.Events():
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Path()) != path || event.Op() == ev.CHMOD {
+					continue
+				}
+				debouncer.Do(onChange)
+
+			case err, ok := <-backend.Errors():
+				if !ok {
+					return
+				}
+				slog.Error("config watcher", slog.Any("error", err))
+			}
+		}
+	}()
+
+	return nil
+}
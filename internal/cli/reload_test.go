@@ -0,0 +1,288 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimmerz92/eavesdrop/v2"
+	"github.com/dimmerz92/eavesdrop/v2/internal/config"
+)
+
+// testWatcherSet returns a watcherSet rooted at a temporary directory, whose watchers are stopped
+// when the test ends.
+func testWatcherSet(t *testing.T) (*watcherSet, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.RootDir = dir
+	cfg.Watchers = nil
+
+	s := newWatcherSet(t.Context(), ev.NewEmitter(dir), nil, cfg)
+	t.Cleanup(func() {
+		for name := range s.running {
+			s.stop(name)
+		}
+	})
+
+	return s, dir
+}
+
+// serviceWatcher returns a watcher whose service records each start and stop in dir.
+func serviceWatcher(dir, name string) config.WatcherConfig {
+	watcher := config.DefaultWatcherConfig(name)
+	watcher.Shell.Service = fmt.Sprintf(
+		`trap "echo run >> '%[1]s.stopped'; exit 0" TERM; echo run >> '%[1]s.started'; while :; do sleep 0.05; done`,
+		filepath.Join(dir, name),
+	)
+	return watcher
+}
+
+// awaitRunning waits until each named watcher has the expected number of services running,
+// as services start in the background.
+func awaitRunning(t *testing.T, running func(name string) int, expected map[string]int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for name, n := range expected {
+		for running(name) != n && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if got := running(name); got != n {
+			t.Errorf("%s: %d services running, expected %d", name, got, n)
+		}
+	}
+}
+
+// countLines returns the number of lines in the file at path, or zero if it does not exist.
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	return strings.Count(string(data), "\n")
+}
+
+func TestWatcherSet_Apply(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh traps")
+	}
+
+	s, dir := testWatcherSet(t)
+
+	same := serviceWatcher(dir, "apply-same")
+	edited := serviceWatcher(dir, "apply-edited")
+	removed := serviceWatcher(dir, "apply-removed")
+	added := serviceWatcher(dir, "apply-added")
+
+	// stops returns how many times each watcher's service was stopped, and running how many of
+	// its services are running.
+	stops := func(name string) int { return countLines(t, filepath.Join(dir, name+".stopped")) }
+	running := func(name string) int { return countLines(t, filepath.Join(dir, name+".started")) - stops(name) }
+
+	if err := s.apply(config.Config{Watchers: []config.WatcherConfig{same, edited, removed}}); err != nil {
+		t.Fatalf("apply() = %v", err)
+	}
+	awaitRunning(t, running, map[string]int{same.Name: 1, edited.Name: 1, removed.Name: 1})
+
+	before := maps.Clone(s.running)
+
+	editedNext := edited
+	editedNext.Shell.DebounceDelay = 200
+
+	if err := s.apply(config.Config{Watchers: []config.WatcherConfig{same, editedNext, added}}); err != nil {
+		t.Fatalf("apply() = %v", err)
+	}
+	awaitRunning(t, running, map[string]int{same.Name: 1, edited.Name: 1, removed.Name: 0, added.Name: 1})
+
+	t.Run("unchanged watcher kept running", func(t *testing.T) {
+		if s.running[same.Name] != before[same.Name] {
+			t.Error("unchanged watcher was recreated")
+		}
+		if got := stops(same.Name); got != 0 {
+			t.Errorf("unchanged watcher's service stopped %d times, expected 0", got)
+		}
+	})
+
+	t.Run("edited watcher recreated", func(t *testing.T) {
+		next, ok := s.running[edited.Name]
+		if !ok || next == before[edited.Name] {
+			t.Fatal("edited watcher was not recreated")
+		}
+		if next.config.Shell.DebounceDelay != 200 {
+			t.Errorf("recreated watcher has debounce delay %d, expected 200", next.config.Shell.DebounceDelay)
+		}
+		if got := stops(edited.Name); got != 1 {
+			t.Errorf("edited watcher's service stopped %d times, expected 1", got)
+		}
+	})
+
+	t.Run("removed watcher stopped", func(t *testing.T) {
+		if _, ok := s.running[removed.Name]; ok {
+			t.Error("removed watcher still running")
+		}
+		if got := stops(removed.Name); got != 1 {
+			t.Errorf("removed watcher's service stopped %d times, expected 1", got)
+		}
+	})
+
+	t.Run("added watcher started", func(t *testing.T) {
+		if _, ok := s.running[added.Name]; !ok {
+			t.Error("added watcher not running")
+		}
+	})
+
+	t.Run("start failure restores previous watchers", func(t *testing.T) {
+		// a log file inside a regular file cannot be created.
+		if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		broken := serviceWatcher(dir, "apply-broken")
+		broken.Output.LogFile = filepath.Join(dir, "file", "broken.log")
+
+		editedAgain := edited
+		editedAgain.Shell.DebounceDelay = 300
+
+		before := maps.Clone(s.running)
+
+		if err := s.apply(config.Config{Watchers: []config.WatcherConfig{same, editedAgain, broken}}); err == nil {
+			t.Fatal("expected error")
+		}
+
+		names := slices.Sorted(maps.Keys(s.running))
+		expected := slices.Sorted(maps.Keys(before))
+		if !slices.Equal(names, expected) {
+			t.Fatalf("running watchers = %v, expected %v", names, expected)
+		}
+
+		if s.running[same.Name] != before[same.Name] || stops(same.Name) != 0 {
+			t.Error("unchanged watcher was disturbed")
+		}
+		if got := s.running[edited.Name].config.Shell.DebounceDelay; got != 200 {
+			t.Errorf("edited watcher has debounce delay %d, expected its previous 200", got)
+		}
+		awaitRunning(t, running, map[string]int{same.Name: 1, edited.Name: 1, added.Name: 1, broken.Name: 0})
+	})
+}
+
+func TestWatcherSet_Lock(t *testing.T) {
+	watcher := func(name, lock string) config.WatcherConfig {
+		watcher := config.DefaultWatcherConfig(name)
+		watcher.Lock = lock
+		return watcher
+	}
+
+	tests := []struct {
+		name   string
+		a, b   config.WatcherConfig
+		shared bool
+	}{
+		{"global watchers share", watcher("a", config.LockGlobal), watcher("b", config.LockGlobal), true},
+		{"unset lock is global", watcher("a", ""), watcher("b", config.LockGlobal), true},
+		{"watcher locks are separate", watcher("a", config.LockWatcher), watcher("b", config.LockWatcher), false},
+		{"watcher lock is not global", watcher("a", config.LockWatcher), watcher("b", config.LockGlobal), false},
+		{"watcher lock outlives reload", watcher("a", config.LockWatcher), watcher("a", config.LockWatcher), true},
+		{"group members share", watcher("a", "assets"), watcher("b", "assets"), true},
+		{"groups are separate", watcher("a", "assets"), watcher("b", "api"), false},
+		{"group is not global", watcher("a", "assets"), watcher("b", config.LockGlobal), false},
+		{"group named after a watcher lock", watcher("a", config.LockWatcher), watcher("b", "watcher/a"), false},
+		{"watcher named global", watcher(config.LockGlobal, config.LockWatcher), watcher("b", config.LockGlobal), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newWatcherSet(t.Context(), nil, nil, config.DefaultConfig())
+
+			if shared := s.lock(test.a) == s.lock(test.b); shared != test.shared {
+				t.Errorf("lock(%s: %q) == lock(%s: %q) = %v, expected %v",
+					test.a.Name, test.a.Lock, test.b.Name, test.b.Lock, shared, test.shared)
+			}
+		})
+	}
+}
+
+func TestWatcherSet_TriggerNext(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	tests := []struct {
+		name      string
+		task      string
+		runMode   string
+		supersede bool
+		expected  map[string]int
+	}{
+		{name: "success", task: "true", runMode: config.RunModeQueue, expected: map[string]int{"on-success": 1, "on-failure": 0}},
+		{name: "failure", task: "exit 1", runMode: config.RunModeQueue, expected: map[string]int{"on-success": 0, "on-failure": 1}},
+		{
+			// the first run fails once cancelled, but gives way to the second without triggering.
+			name:      "superseded",
+			task:      "test -f fast || sleep 5",
+			runMode:   config.RunModeLatest,
+			supersede: true,
+			expected:  map[string]int{"on-success": 1, "on-failure": 0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, dir := testWatcherSet(t)
+
+			prefix := "trigger-" + test.name + "-"
+			target := func(name string) config.WatcherConfig {
+				watcher := config.DefaultWatcherConfig(prefix + name)
+				watcher.RunOnStart = false
+				watcher.Shell.Tasks = []config.Task{{Command: fmt.Sprintf("echo run >> '%s'", filepath.Join(dir, name))}}
+				return watcher
+			}
+
+			source := config.DefaultWatcherConfig(prefix + "source")
+			source.RunOnStart = !test.supersede
+			source.RunMode = test.runMode
+			source.Shell.Cwd = dir
+			source.Shell.Tasks = []config.Task{{Command: test.task}}
+			source.OnSuccess = []string{prefix + "on-success"}
+			source.OnFailure = []string{prefix + "on-failure"}
+
+			cfg := config.Config{Watchers: []config.WatcherConfig{target("on-success"), target("on-failure"), source}}
+			if err := s.apply(cfg); err != nil {
+				t.Fatalf("apply() = %v", err)
+			}
+
+			if test.supersede {
+				watcher := s.running[source.Name].watcher
+				go watcher.Trigger()
+				time.Sleep(200 * time.Millisecond)
+
+				if err := os.WriteFile(filepath.Join(dir, "fast"), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				watcher.Trigger()
+			}
+
+			// wait for the triggered watcher, then long enough for a wrongly triggered one to run.
+			deadline := time.Now().Add(2 * time.Second)
+			for name, n := range test.expected {
+				for n > 0 && countLines(t, filepath.Join(dir, name)) < n && time.Now().Before(deadline) {
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+			time.Sleep(200 * time.Millisecond)
+
+			for name, n := range test.expected {
+				if got := countLines(t, filepath.Join(dir, name)); got != n {
+					t.Errorf("%s ran %d times, expected %d", name, got, n)
+				}
+			}
+		})
+	}
+}
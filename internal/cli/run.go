@@ -8,14 +8,18 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"sync"
 
-	"github.com/dimmerz92/eavesdrop/v2/internal/config"
+	"github.com/fatih/color"
 )
 
 var defaultConfigNames = []string{"eavesdrop.json", "eavesdrop.toml", "eavesdrop.yaml"}
 
-func findDefaultConfig() (string, error) {
+// findConfig returns path, or if it is empty, the first default config file that exists.
+func findConfig(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+
 	for _, name := range defaultConfigNames {
 		if _, err := os.Stat(name); err == nil {
 			return name, nil
@@ -30,18 +34,15 @@ func RunEavesdrop(ctx context.Context) {
 	path := flag.String("config", "", "the path to the config file")
 	flag.Parse()
 
-	configPath := *path
-	if configPath == "" {
-		var err error
-		configPath, err = findDefaultConfig()
-		if err != nil {
-			panic(err)
-		}
+	configPath, err := findConfig(*path)
+	if err != nil {
+		color.Red("%v", err)
+		os.Exit(1)
 	}
 
-	config, err := config.GetConfig(configPath)
-	if err != nil {
-		panic(err)
+	config, ok := loadConfig(configPath)
+	if !ok {
+		os.Exit(1)
 	}
 
 	proxy, err := ConstructProxy(ctx, config.Proxy)
@@ -49,17 +50,21 @@ func RunEavesdrop(ctx context.Context) {
 		panic(err)
 	}
 
-	emitter := ConstructEventEmitter(ctx, config)
+	emitter, err := ConstructEventEmitter(ctx, config)
+	if err != nil {
+		panic(err)
+	}
 
 	emitter.Start(ctx)
 
-	var mu sync.Mutex
-	for _, watcherConfig := range config.Watchers {
-		watcher := ConstructWatcher(ctx, config.RootDir, &mu, proxy, watcherConfig)
-		emitter.Subscribe(watcher)
-		if watcherConfig.RunOnStart {
-			watcher.Trigger()
-		}
+	watchers := newWatcherSet(ctx, emitter, proxy, config)
+	if err := watchers.apply(config); err != nil {
+		panic(err)
+	}
+
+	err = watchConfig(ctx, config, configPath, func() { watchers.reload(configPath) })
+	if err != nil {
+		slog.Error("watching config file", slog.Any("error", err))
 	}
 
 	if config.Tmp {
@@ -1,37 +1,308 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
 	"sync"
 
 	"github.com/dimmerz92/eavesdrop/v2"
+	"github.com/dimmerz92/eavesdrop/v2/internal/components"
+	"github.com/dimmerz92/eavesdrop/v2/internal/config"
 	"github.com/fatih/color"
 )
 
-func NewShellRunner(shell *ev.Shell, name string, mu *sync.Mutex, tasks []string, service string) func(ev.Event) {
-	return func(event ev.Event) {
+// CaptureLimit is the amount of task output in kilobytes retained for the error overlay.
+const CaptureLimit = 64
+
+// Overlay displays task failures in the browser, implemented by the proxy.
+type Overlay interface {
+	ShowError(source, title, output string)
+	ClearError(source string)
+}
+
+var (
+	_ Overlay            = (*components.Proxy)(nil)
+	_ ev.StylesheetProxy = (*components.Proxy)(nil)
+)
+
+// Environment variables describing the change that triggered a run, set for every task and service.
+const (
+	EnvWatcher = "EAVESDROP_WATCHER" // the name of the watcher that fired
+	EnvPath    = "EAVESDROP_PATH"    // the most recently changed path
+	EnvOp      = "EAVESDROP_OP"      // the operation(s) on EAVESDROP_PATH, e.g. WRITE
+	EnvPaths   = "EAVESDROP_PATHS"   // every path changed during the debounce window, one per line
+)
+
+// Service is a long-running command started by a watcher, with its own Shell.
+type Service struct {
+	Name    string // the name shown in output, e.g. "web/api"
+	Command string
+	Shell   *ev.Shell
+}
+
+// StopServices gracefully stops every service concurrently, each within its shutdown timeout.
+func StopServices(services []Service) error {
+	errs := make([]error, len(services))
+
+	var wg sync.WaitGroup
+	for i, service := range services {
+		wg.Go(func() {
+			if err := service.Shell.Stop(); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", service.Name, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// NewShellRunner returns a handler that stops the services, runs the tasks, and starts the
+// services again. Tasks run as a graph of their dependencies, in parallel where possible, and
+// the services are only started if every task succeeds or is allowed to fail. done is then called
+// with whether they did, unless the run was superseded. The handler returns whether the services
+// were started, so that the watcher only awaits their readiness then. Each task's output is
+// written to the writers returned by output, which also copy it to the task's own capture for
+// the overlay.
+//
+// In config.RunModeLatest, a new change cancels the tasks of a run in progress, killing those
+// running and skipping the rest, and the run gives way to the newest one without starting services
+// or calling done; superseded runs waiting on mu are dropped.
+func NewShellRunner(
+	shell *ev.Shell,
+	name string,
+	mu *sync.Mutex,
+	mode string,
+	tasks []config.Task,
+	services []Service,
+	overlay Overlay,
+	output func(capture io.Writer) (stdout, stderr io.Writer),
+	done func(succeeded bool),
+) func([]ev.Event) bool {
+	deps := config.Dependencies(tasks)
+
+	var (
+		genMu      sync.Mutex
+		generation uint64
+		cancelRun  context.CancelFunc // cancels the tasks of the run in progress, if any
+	)
+
+	return func(batch []ev.Event) bool {
+		genMu.Lock()
+		generation++
+		gen := generation
+		cancel := mode == config.RunModeLatest && cancelRun != nil
+		if cancel {
+			cancelRun()
+		}
+		genMu.Unlock()
+
+		// superseded reports whether a newer change has arrived in RunModeLatest.
+		superseded := func() bool {
+			if mode != config.RunModeLatest {
+				return false
+			}
+			genMu.Lock()
+			defer genMu.Unlock()
+			return generation != gen
+		}
+
+		if cancel {
+			color.Yellow("%s: cancelling running tasks for a newer change", name)
+		}
+
 		mu.Lock()
 		defer mu.Unlock()
 
-		err := shell.KillProcessGroup()
+		// Tasks run under ctx, so a newer change cancels them even if they have yet to start.
+		genMu.Lock()
+		if mode == config.RunModeLatest && generation != gen {
+			genMu.Unlock()
+			return false
+		}
+		ctx, cancelTasks := context.WithCancel(context.Background())
+		cancelRun = cancelTasks
+		genMu.Unlock()
+
+		defer func() {
+			genMu.Lock()
+			cancelTasks()
+			cancelRun = nil
+			genMu.Unlock()
+		}()
+
+		change := NewChange(name, batch)
+
+		env := change.Environ()
+		shell.SetEventEnv(env...)
+		for _, service := range services {
+			service.Shell.SetEventEnv(env...)
+		}
+
+		err := StopServices(services)
 		if err != nil {
-			color.Red("%s: failed to kill previous service: %v", name, err)
+			color.Red("%s: failed to stop previous services: %v", name, err)
 		}
 
-		for _, task := range tasks {
-			fmt.Printf("%s: running task: %s\n", color.CyanString(name), task)
-			err := shell.ExecAndWait(task)
-			if err != nil {
-				color.Red("%s: failed to run task: %v", name, err)
+		var (
+			failMu sync.Mutex
+			failed bool
+		)
+
+		states := components.RunGraph(deps, func(i int) bool {
+			if superseded() {
+				return false
+			}
+
+			task := change.Replace(tasks[i], shell.Quote)
+			fmt.Printf("%s: running task: %s\n", color.CyanString(name), taskLabel(task))
+
+			capture := components.NewCapture(CaptureLimit)
+			stdout, stderr := output(capture)
+
+			var err error
+			if task.IsArgv() {
+				err = shell.ExecArgsAndWaitContext(ctx, stdout, stderr, task.Args...)
+			} else {
+				err = shell.ExecAndWaitContext(ctx, stdout, stderr, task.Command)
+			}
+
+			if err == nil {
+				return true
+			}
+
+			if superseded() {
+				return false
 			}
+
+			color.Red("%s: failed to run task: %s: %v", name, taskLabel(task), err)
+
+			failMu.Lock()
+			if !failed && overlay != nil {
+				overlay.ShowError(name, task.String(), fmt.Sprintf("%s\n%v", capture.String(), err))
+			}
+			failed = true
+			failMu.Unlock()
+
+			return task.ContinueOnError
+		})
+
+		if superseded() {
+			return false
+		}
+
+		if !failed && overlay != nil {
+			overlay.ClearError(name)
+		}
+
+		succeeded := true
+		for i, state := range states {
+			if state == components.JOB_SKIPPED {
+				color.Yellow("%s: skipped task: %s", name, taskLabel(tasks[i]))
+			}
+			succeeded = succeeded && state == components.JOB_SUCCEEDED
 		}
 
-		if service != "" {
-			fmt.Printf("%s: running service: %s\n", color.BlueString(name), service)
-			err := shell.ExecAndReturn(service)
+		if done != nil {
+			defer done(succeeded)
+		}
+
+		if !succeeded {
+			if len(services) > 0 {
+				color.Red("%s: not starting services as a required task failed", name)
+			}
+			return false
+		}
+
+		for _, service := range services {
+			fmt.Printf("%s: running service: %s\n", color.BlueString(service.Name), service.Command)
+			err := service.Shell.ExecAndReturn(service.Command)
 			if err != nil {
-				color.Red("%s: failed to run service: %v", name, err)
+				color.Red("%s: failed to run service: %v", service.Name, err)
 			}
 		}
+
+		return true
+	}
+}
+
+// Change describes the batch of events that triggered a run, as passed to its tasks.
+type Change struct {
+	Watcher string
+	Path    string // the most recently changed path, or empty for a manual trigger
+	Op      string
+	Paths   []string
+}
+
+// NewChange returns the change made by batch to the watcher called name.
+func NewChange(name string, batch []ev.Event) Change {
+	change := Change{Watcher: name, Paths: make([]string, 0, len(batch))}
+	for _, event := range batch {
+		change.Paths = append(change.Paths, event.Path())
+	}
+	if len(batch) > 0 {
+		last := batch[len(batch)-1]
+		change.Path, change.Op = last.Path(), last.Op().String()
+	}
+	return change
+}
+
+// Environ returns the change as environment variables in KEY=value form.
+func (c Change) Environ() []string {
+	return []string{
+		EnvWatcher + "=" + c.Watcher,
+		EnvPath + "=" + c.Path,
+		EnvOp + "=" + c.Op,
+		EnvPaths + "=" + strings.Join(c.Paths, "\n"),
+	}
+}
+
+// Replace substitutes the change for the placeholders in task. In a shell task, each value is
+// passed through quote, so paths with spaces or shell metacharacters stay single arguments and
+// are never run. In an argv task, values are substituted as is, and an argument that is exactly
+// {{paths}} is replaced by one argument per path.
+func (c Change) Replace(task config.Task, quote func(string) string) config.Task {
+	if !task.IsArgv() {
+		task.Command = c.replacer(quote).Replace(task.Command)
+		return task
+	}
+
+	placeholders := c.replacer(func(s string) string { return s })
+
+	args := make([]string, 0, len(task.Args))
+	for _, arg := range task.Args {
+		if arg == "{{paths}}" {
+			args = append(args, c.Paths...)
+			continue
+		}
+		args = append(args, placeholders.Replace(arg))
+	}
+	task.Args = args
+	return task
+}
+
+// replacer returns a Replacer substituting each placeholder with its value passed through quote.
+func (c Change) replacer(quote func(string) string) *strings.Replacer {
+	paths := make([]string, len(c.Paths))
+	for i, path := range c.Paths {
+		paths[i] = quote(path)
+	}
+
+	return strings.NewReplacer(
+		"{{watcher}}", quote(c.Watcher),
+		"{{path}}", quote(c.Path),
+		"{{op}}", quote(c.Op),
+		"{{paths}}", strings.Join(paths, " "),
+	)
+}
+
+// taskLabel returns the task's command, prefixed with its name if it has one.
+func taskLabel(task config.Task) string {
+	if task.Name == "" {
+		return task.String()
 	}
+	return task.Name + " (" + task.String() + ")"
 }
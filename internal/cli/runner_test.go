@@ -0,0 +1,301 @@
+package cli_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dimmerz92/eavesdrop/v2"
+	"github.com/dimmerz92/eavesdrop/v2/internal/cli"
+	"github.com/dimmerz92/eavesdrop/v2/internal/config"
+)
+
+func TestChange_Replace(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX quoting")
+	}
+
+	quote := ev.NewShell(t.Context(), 50, 50).Quote
+
+	change := cli.NewChange("app", []ev.Event{
+		ev.NewEvent(ev.WRITE, "with space.go", nil),
+		ev.NewEvent(ev.CREATE, "a;rm -rf ~.go", nil),
+		ev.NewEvent(ev.WRITE, "$(touch pwned).go", nil),
+	})
+
+	tests := []struct {
+		name     string
+		task     config.Task
+		expected config.Task
+	}{
+		{
+			name:     "shell watcher and op",
+			task:     config.Task{Command: "echo {{watcher}} {{op}}"},
+			expected: config.Task{Command: "echo 'app' 'WRITE'"},
+		},
+		{
+			name:     "shell path quoted",
+			task:     config.Task{Command: "go test ./$(dirname {{path}})"},
+			expected: config.Task{Command: "go test ./$(dirname '$(touch pwned).go')"},
+		},
+		{
+			name:     "shell paths each quoted",
+			task:     config.Task{Command: "gofmt -l {{paths}}"},
+			expected: config.Task{Command: "gofmt -l 'with space.go' 'a;rm -rf ~.go' '$(touch pwned).go'"},
+		},
+		{
+			name:     "shell task fields kept",
+			task:     config.Task{Name: "fmt", Command: "gofmt {{path}}", DependsOn: []string{"gen"}},
+			expected: config.Task{Name: "fmt", Command: "gofmt '$(touch pwned).go'", DependsOn: []string{"gen"}},
+		},
+		{
+			name:     "argv values unquoted",
+			task:     config.Task{Args: []string{"echo", "{{watcher}}:{{path}}"}},
+			expected: config.Task{Args: []string{"echo", "app:$(touch pwned).go"}},
+		},
+		{
+			name:     "argv paths expanded to arguments",
+			task:     config.Task{Args: []string{"gofmt", "-l", "{{paths}}"}},
+			expected: config.Task{Args: []string{"gofmt", "-l", "with space.go", "a;rm -rf ~.go", "$(touch pwned).go"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := change.Replace(test.task, quote); !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("Replace() = %+v, expected %+v", got, test.expected)
+			}
+		})
+	}
+
+	t.Run("manual trigger", func(t *testing.T) {
+		change := cli.NewChange("app", nil)
+
+		got := change.Replace(config.Task{Command: "echo {{path}} {{paths}}"}, quote)
+		if expected := "echo '' "; got.Command != expected {
+			t.Errorf("Replace() = %q, expected %q", got.Command, expected)
+		}
+	})
+
+	t.Run("metacharacters not run", func(t *testing.T) {
+		dir := t.TempDir()
+
+		var stdout bytes.Buffer
+		shell := ev.NewShell(t.Context(), 1000, 50, ev.WithWorkingDir(dir)).WithOutput(&stdout, &stdout)
+
+		task := change.Replace(config.Task{Command: "printf '%s|' {{paths}}"}, shell.Quote)
+		if err := shell.ExecAndWait(task.Command); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if expected := "with space.go|a;rm -rf ~.go|$(touch pwned).go|"; stdout.String() != expected {
+			t.Errorf("expected %q, got %q", expected, stdout.String())
+		}
+		if _, err := os.Stat(filepath.Join(dir, "pwned")); err == nil {
+			t.Error("command substitution in a path was run")
+		}
+	})
+}
+
+func TestChange_Environ(t *testing.T) {
+	change := cli.NewChange("app", []ev.Event{
+		ev.NewEvent(ev.CREATE, "a.go", nil),
+		ev.NewEvent(ev.WRITE, "b c.go", nil),
+	})
+
+	expected := []string{
+		"EAVESDROP_WATCHER=app",
+		"EAVESDROP_PATH=b c.go",
+		"EAVESDROP_OP=WRITE",
+		"EAVESDROP_PATHS=a.go\nb c.go",
+	}
+	if got := change.Environ(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Environ() = %q, expected %q", got, expected)
+	}
+}
+
+type mockOverlay struct {
+	mu     sync.Mutex
+	errors map[string]string
+}
+
+func (m *mockOverlay) ShowError(source, title, output string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors[title] = output
+}
+
+func (m *mockOverlay) ClearError(source string) {}
+
+func TestNewShellRunner_TaskOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	// Both tasks run in parallel; the overlay shows only the failing task's output.
+	tasks := []config.Task{
+		{Name: "slow", Command: "echo from-slow; sleep 0.2; echo more-slow", DependsOn: []string{}},
+		{Name: "fail", Command: "sleep 0.1; echo from-fail; exit 1", DependsOn: []string{}},
+	}
+
+	overlay := &mockOverlay{errors: make(map[string]string)}
+	output := func(capture io.Writer) (io.Writer, io.Writer) { return capture, capture }
+
+	shell := ev.NewShell(t.Context(), 5000, 50)
+	run := cli.NewShellRunner(shell, "app", &sync.Mutex{}, config.RunModeQueue, tasks, nil, overlay, output, nil)
+	run(nil)
+
+	got, ok := overlay.errors[tasks[1].Command]
+	if !ok {
+		t.Fatalf("overlay did not show the failing task, got %v", overlay.errors)
+	}
+	if !strings.Contains(got, "from-fail") {
+		t.Errorf("overlay output %q missing the failing task's output", got)
+	}
+	if strings.Contains(got, "slow") {
+		t.Errorf("overlay output %q contains another task's output", got)
+	}
+}
+
+func TestNewShellRunner_RunModeLatest(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	// Runs for c.go record the path, any other run sleeps until it is cancelled.
+	tasks := []config.Task{{Command: `case "$EAVESDROP_PATH" in c.go) echo "$EAVESDROP_PATH" >> out ;; *) sleep 5 ;; esac`}}
+	output := func(capture io.Writer) (io.Writer, io.Writer) { return io.Discard, io.Discard }
+
+	setup := func(t *testing.T) (func([]ev.Event) bool, *sync.Mutex, func() []bool, string) {
+		dir := t.TempDir()
+
+		var (
+			mu      sync.Mutex
+			doneMu  sync.Mutex
+			results []bool
+		)
+		done := func(succeeded bool) {
+			doneMu.Lock()
+			defer doneMu.Unlock()
+			results = append(results, succeeded)
+		}
+		got := func() []bool {
+			doneMu.Lock()
+			defer doneMu.Unlock()
+			return results
+		}
+
+		shell := ev.NewShell(t.Context(), 10000, 50, ev.WithWorkingDir(dir))
+		overlay := &mockOverlay{errors: make(map[string]string)}
+		run := cli.NewShellRunner(shell, "app", &mu, config.RunModeLatest, tasks, nil, overlay, output, done)
+		return run, &mu, got, dir
+	}
+
+	batch := func(path string) []ev.Event { return []ev.Event{ev.NewEvent(ev.WRITE, path, nil)} }
+
+	t.Run("cancels running tasks", func(t *testing.T) {
+		run, _, done, dir := setup(t)
+
+		first := make(chan struct{})
+		go func() {
+			defer close(first)
+			run(batch("a.go"))
+		}()
+		time.Sleep(200 * time.Millisecond)
+
+		start := time.Now()
+		run(batch("c.go"))
+
+		select {
+		case <-first:
+		case <-time.After(2 * time.Second):
+			t.Fatal("superseded run was not cancelled")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("newest run took %v", elapsed)
+		}
+
+		if got := done(); !reflect.DeepEqual(got, []bool{true}) {
+			t.Errorf("expected done called once with true, got %v", got)
+		}
+		if out, _ := os.ReadFile(filepath.Join(dir, "out")); string(out) != "c.go\n" {
+			t.Errorf("expected only the newest run's output, got %q", out)
+		}
+	})
+
+	t.Run("drops queued runs", func(t *testing.T) {
+		run, mu, done, dir := setup(t)
+
+		// Hold the lock so that the first run queues behind it and is superseded while waiting.
+		mu.Lock()
+		var wg sync.WaitGroup
+		wg.Go(func() { run(batch("a.go")) })
+		time.Sleep(50 * time.Millisecond)
+		wg.Go(func() { run(batch("c.go")) })
+		time.Sleep(50 * time.Millisecond)
+		mu.Unlock()
+
+		finished := make(chan struct{})
+		go func() {
+			defer close(finished)
+			wg.Wait()
+		}()
+		select {
+		case <-finished:
+		case <-time.After(2 * time.Second):
+			t.Fatal("superseded queued run was not dropped")
+		}
+
+		if got := done(); !reflect.DeepEqual(got, []bool{true}) {
+			t.Errorf("expected done called once with true, got %v", got)
+		}
+		if out, _ := os.ReadFile(filepath.Join(dir, "out")); string(out) != "c.go\n" {
+			t.Errorf("expected only the newest run's output, got %q", out)
+		}
+	})
+}
+
+func TestNewShellRunner_Done(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	tests := []struct {
+		name     string
+		tasks    []config.Task
+		expected []bool
+	}{
+		{name: "no tasks succeed", tasks: nil, expected: []bool{true}},
+		{name: "tasks succeed", tasks: []config.Task{{Command: "true"}, {Command: "true"}}, expected: []bool{true}},
+		{name: "required task fails", tasks: []config.Task{{Command: "true"}, {Command: "exit 1"}}, expected: []bool{false}},
+		{name: "task allowed to fail", tasks: []config.Task{{Command: "exit 1", ContinueOnError: true}, {Command: "true"}}, expected: []bool{true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []bool
+			done := func(succeeded bool) { got = append(got, succeeded) }
+
+			overlay := &mockOverlay{errors: make(map[string]string)}
+			output := func(capture io.Writer) (io.Writer, io.Writer) { return io.Discard, io.Discard }
+
+			shell := ev.NewShell(t.Context(), 5000, 50)
+			run := cli.NewShellRunner(shell, "app", &sync.Mutex{}, config.RunModeQueue, test.tasks, nil, overlay, output, done)
+			started := run(nil)
+
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("done called with %v, expected %v", got, test.expected)
+			}
+			if started != test.expected[0] {
+				t.Errorf("run() = %v, expected %v", started, test.expected[0])
+			}
+		})
+	}
+}
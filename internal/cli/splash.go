@@ -31,6 +31,8 @@ var Help = Splash +
 	fmt.Sprintf("%s %s: Generates a config file.\n", color.BlueString("init"), color.MagentaString("[options]")) +
 	fmt.Sprintf("\t%s: directory to save the generated config. Defaults to [.]\n", color.MagentaString("-out")) +
 	fmt.Sprintf("\t%s: the filetype to generate (json, toml, yaml). Defaults to json\n", color.MagentaString("-ext")) +
+	fmt.Sprintf("\n%s %s: Checks a config file and reports every problem found.\n", color.BlueString("validate"), color.MagentaString("[options]")) +
+	fmt.Sprintf("\t%s: the path of the config file. Auto-detected if omitted\n", color.MagentaString("-config")) +
 	fmt.Sprintf("\n%s: Prints the help text for eavesdrop\n\n", color.BlueString("help")) +
 	fmt.Sprintf("%s: can be used without any commands\n\n", color.YellowString("OPTIONS:")) +
 	fmt.Sprintf("%s: The path of the config file. Auto-detected from eavesdrop.{json,toml,yaml} in the current directory", color.MagentaString("-config"))
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dimmerz92/eavesdrop/v2/internal/config"
+	"github.com/fatih/color"
+)
+
+// RunValidate checks the config file at path, or the auto-detected one if path is empty, and
+// reports every problem found. Returns false if the config is invalid or cannot be read.
+func RunValidate(path string) bool {
+	path, err := findConfig(path)
+	if err != nil {
+		color.Red("%v", err)
+		return false
+	}
+
+	_, ok := loadConfig(path)
+	if ok {
+		color.Green("config is valid")
+	}
+	return ok
+}
+
+// loadConfig reads and validates the config file at path, printing any problems. Returns false
+// if the config is invalid or cannot be read.
+func loadConfig(path string) (config.Config, bool) {
+	cfg, problems, err := config.ValidateFile(path)
+	if err != nil {
+		color.Red("%s: %v", path, err)
+		return cfg, false
+	}
+
+	for _, problem := range problems {
+		location := path
+		if problem.Line > 0 {
+			location = fmt.Sprintf("%s:%d", path, problem.Line)
+		}
+		color.Red("%s: %s: %s", location, problem.Field, problem.Message)
+	}
+
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%d problem(s) found\n", len(problems))
+		return cfg, false
+	}
+
+	return cfg, true
+}
@@ -26,6 +26,17 @@ func (d *Debouncer) Do(callback func()) {
 	d.timer = time.AfterFunc(d.delay, callback)
 }
 
+// Stop cancels the pending callback, if any.
+func (d *Debouncer) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+}
+
 func (d *Debouncer) UpdateDelay(delayMs uint) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -129,3 +129,19 @@ func TestDebouncer_Do(t *testing.T) {
 		})
 	}
 }
+
+func TestDebouncer_Stop(t *testing.T) {
+	const delay = 50
+
+	d := components.NewDebouncer(delay)
+
+	var count atomic.Int32
+	d.Do(func() { count.Add(1) })
+	d.Stop()
+
+	time.Sleep(delay * 3 * time.Millisecond)
+
+	if got := count.Load(); got != 0 {
+		t.Errorf("callback fired %d time(s) after Stop(), expected 0", got)
+	}
+}
@@ -0,0 +1,251 @@
+package components
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Gitignore matches paths against the ignore rules of a git work tree: .git/info/exclude and
+// every .gitignore file from the root down, with the same precedence and pattern semantics as git.
+// Paths inside the .git directory are always ignored.
+type Gitignore struct {
+	rules []gitignoreRule
+}
+
+// gitignoreRule is a single pattern, applying to paths beneath base.
+type gitignoreRule struct {
+	base    string
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// LoadGitignore reads the ignore rules for the work tree at root. Directories that are ignored
+// are not searched for further .gitignore files, as in git. Files that cannot be read are skipped
+// and reported in the returned error, alongside the rules that could be loaded.
+func LoadGitignore(root string) (*Gitignore, error) {
+	g := &Gitignore{}
+
+	errs := []error{g.load(filepath.Join(root, ".git", "info", "exclude"), "")}
+
+	filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return nil
+		}
+
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		} else if g.Match(rel, true) {
+			return fs.SkipDir
+		}
+
+		errs = append(errs, g.load(filepath.Join(p, ".gitignore"), rel))
+
+		return nil
+	})
+
+	return g, errors.Join(errs...)
+}
+
+// load appends the rules in the ignore file at name, which apply to paths beneath base.
+func (g *Gitignore) load(name, base string) error {
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseGitignoreRule(base, scanner.Text()); ok {
+			g.rules = append(g.rules, rule)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Match reports whether the slash-separated path, relative to the root, is ignored. A path is
+// ignored if it or any of its parent directories is matched by the last rule that applies to it.
+func (g *Gitignore) Match(rel string, isDir bool) bool {
+	rel = path.Clean(rel)
+	if rel == "." || strings.HasPrefix(rel, "../") {
+		return false
+	}
+
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		if parts[i] == ".git" {
+			return true
+		}
+
+		last := i == len(parts)-1
+		if g.matchOne(strings.Join(parts[:i+1], "/"), isDir || !last) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchOne reports whether the last rule matching rel ignores it, without considering parents.
+func (g *Gitignore) matchOne(rel string, isDir bool) bool {
+	ignored := false
+
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		sub := rel
+		if rule.base != "" {
+			var ok bool
+			sub, ok = strings.CutPrefix(rel, rule.base+"/")
+			if !ok {
+				continue
+			}
+		}
+
+		if rule.pattern.MatchString(sub) {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
+
+// parseGitignoreRule parses one line of an ignore file, returning false for blank lines,
+// comments, and invalid patterns.
+func parseGitignoreRule(base, line string) (gitignoreRule, bool) {
+	rule := gitignoreRule{base: base}
+
+	line = strings.TrimSuffix(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+
+	if line == "" || line[0] == '#' {
+		return rule, false
+	}
+
+	if line[0] == '!' {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	// a slash anywhere but the end anchors the pattern to its file's directory.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return rule, false
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+
+	segments := strings.Split(line, "/")
+	for i, segment := range segments {
+		last := i == len(segments)-1
+
+		if segment == "**" {
+			if last {
+				b.WriteString(".*")
+			} else {
+				b.WriteString("(?:.*/)?")
+			}
+			continue
+		}
+
+		b.WriteString(globToRegex(segment))
+		if !last {
+			b.WriteString("/")
+		}
+	}
+	b.WriteString("$")
+
+	pattern, err := regexp.Compile(b.String())
+	if err != nil {
+		return rule, false
+	}
+	rule.pattern = pattern
+
+	return rule, true
+}
+
+// globToRegex converts a single path segment of a gitignore glob to a regular expression.
+func globToRegex(glob string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			for i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+			}
+			b.WriteString("[^/]*")
+
+		case '?':
+			b.WriteString("[^/]")
+
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+
+		case '[':
+			end := i + 1
+			if end < len(glob) && (glob[end] == '!' || glob[end] == '^') {
+				end++
+			}
+			if end < len(glob) && glob[end] == ']' {
+				end++
+			}
+			for end < len(glob) && glob[end] != ']' {
+				end++
+			}
+
+			if end >= len(glob) {
+				b.WriteString(`\[`)
+				continue
+			}
+
+			class := glob[i+1 : end]
+			if class[0] == '!' {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i = end
+
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,128 @@
+package components_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dimmerz92/eavesdrop/v2/internal/components"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGitignore_Match(t *testing.T) {
+	root := t.TempDir()
+
+	writeFile(t, filepath.Join(root, ".git", "info", "exclude"), "*.local\nkeep.me\n")
+	writeFile(t, filepath.Join(root, ".gitignore"), `# comment
+*.log
+!important.log
+/build
+dist/
+docs/**/*.pdf
+**/testdata/**
+cache/*
+!cache/keep
+\#hash
+trailing   
+file?.txt
+[ab].tmp
+[!c]x.bin
+!keep.me
+`)
+	writeFile(t, filepath.Join(root, "web", ".gitignore"), "*.css\n!main.log\n/local\n")
+	writeFile(t, filepath.Join(root, "dist", ".gitignore"), "!*\n")
+
+	g, err := components.LoadGitignore(root)
+	if err != nil {
+		t.Fatalf("LoadGitignore() = %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{path: "main.go", expected: false},
+		{path: "debug.log", expected: true},
+		{path: "sub/dir/debug.log", expected: true},
+		{path: "important.log", expected: false},
+		{path: "build", isDir: true, expected: true},
+		{path: "build/out.bin", expected: true},
+		{path: "src/build", isDir: true, expected: false},
+		{path: "dist", isDir: true, expected: true},
+		{path: "dist", expected: false},
+		{path: "dist/app.js", expected: true}, // a parent dir is excluded, so negation cannot re-include
+		{path: "src/dist/app.js", expected: true},
+		{path: "docs/manual.pdf", expected: true},
+		{path: "docs/a/b/manual.pdf", expected: true},
+		{path: "src/docs/manual.pdf", expected: false},
+		{path: "pkg/testdata/case.txt", expected: true},
+		{path: "testdata/case.txt", expected: true},
+		{path: "testdata", isDir: true, expected: false},
+		{path: "cache/data", expected: true},
+		{path: "cache/keep", expected: false},
+		{path: "#hash", expected: true},
+		{path: "trailing", expected: true},
+		{path: "file1.txt", expected: true},
+		{path: "file10.txt", expected: false},
+		{path: "a.tmp", expected: true},
+		{path: "c.tmp", expected: false},
+		{path: "ax.bin", expected: true},
+		{path: "cx.bin", expected: false},
+		{path: "notes.local", expected: true},
+		{path: "keep.me", expected: false},
+		{path: "web/site.css", expected: true},
+		{path: "site.css", expected: false},
+		{path: "web/main.log", expected: false},
+		{path: "web/other.log", expected: true},
+		{path: "web/local", expected: true},
+		{path: "web/sub/local", expected: false},
+		{path: ".git", isDir: true, expected: true},
+		{path: ".git/HEAD", expected: true},
+		{path: ".", isDir: true, expected: false},
+		{path: "../outside.log", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			if got := g.Match(test.path, test.isDir); got != test.expected {
+				t.Errorf("Match(%q, %v) = %v, expected %v", test.path, test.isDir, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestLoadGitignore(t *testing.T) {
+	t.Run("no ignore files", func(t *testing.T) {
+		g, err := components.LoadGitignore(t.TempDir())
+		if err != nil {
+			t.Fatalf("LoadGitignore() = %v", err)
+		}
+		if g.Match("main.go", false) {
+			t.Error("Match() = true, expected false")
+		}
+	})
+
+	t.Run("ignored dirs are not searched", func(t *testing.T) {
+		root := t.TempDir()
+		writeFile(t, filepath.Join(root, ".gitignore"), "vendor/\n")
+		writeFile(t, filepath.Join(root, "vendor", ".gitignore"), "*.go\n")
+
+		g, err := components.LoadGitignore(root)
+		if err != nil {
+			t.Fatalf("LoadGitignore() = %v", err)
+		}
+		if g.Match("main.go", false) {
+			t.Error("rules from an ignored directory were loaded")
+		}
+	})
+}
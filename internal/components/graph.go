@@ -0,0 +1,79 @@
+package components
+
+// JobState is the outcome of a job run by RunGraph.
+type JobState int
+
+const (
+	JOB_SKIPPED JobState = iota
+	JOB_SUCCEEDED
+	JOB_FAILED
+)
+
+func (s JobState) String() string {
+	switch s {
+	case JOB_SUCCEEDED:
+		return "succeeded"
+	case JOB_FAILED:
+		return "failed"
+	default:
+		return "skipped"
+	}
+}
+
+// RunGraph runs jobs 0 to len(deps)-1 concurrently, starting each once every job listed in its
+// deps has succeeded, and returns the state of each job. run reports whether a job succeeded.
+// Jobs that depend on a failed or skipped job are skipped, as are jobs in a dependency cycle.
+func RunGraph(deps [][]int, run func(job int) bool) []JobState {
+	type result struct {
+		job int
+		ok  bool
+	}
+
+	states := make([]JobState, len(deps))
+	dependents := make([][]int, len(deps))
+	waiting := make([]int, len(deps))
+
+	for job, jobDeps := range deps {
+		for _, dep := range jobDeps {
+			if dep < 0 || dep >= len(deps) {
+				continue
+			}
+			dependents[dep] = append(dependents[dep], job)
+			waiting[job]++
+		}
+	}
+
+	results := make(chan result)
+	running := 0
+
+	start := func(job int) {
+		running++
+		go func() { results <- result{job, run(job)} }()
+	}
+
+	for job := range deps {
+		if waiting[job] == 0 {
+			start(job)
+		}
+	}
+
+	for running > 0 {
+		r := <-results
+		running--
+
+		if !r.ok {
+			states[r.job] = JOB_FAILED
+			continue
+		}
+
+		states[r.job] = JOB_SUCCEEDED
+		for _, dependent := range dependents[r.job] {
+			waiting[dependent]--
+			if waiting[dependent] == 0 {
+				start(dependent)
+			}
+		}
+	}
+
+	return states
+}
@@ -0,0 +1,102 @@
+package components_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dimmerz92/eavesdrop/v2/internal/components"
+)
+
+func TestRunGraph(t *testing.T) {
+	tests := []struct {
+		name     string
+		deps     [][]int
+		fail     []int
+		expected []components.JobState
+	}{
+		{
+			name: "no jobs",
+		},
+		{
+			name:     "independent jobs",
+			deps:     [][]int{nil, nil, nil},
+			expected: []components.JobState{components.JOB_SUCCEEDED, components.JOB_SUCCEEDED, components.JOB_SUCCEEDED},
+		},
+		{
+			name:     "chain stops at failure",
+			deps:     [][]int{nil, {0}, {1}},
+			fail:     []int{1},
+			expected: []components.JobState{components.JOB_SUCCEEDED, components.JOB_FAILED, components.JOB_SKIPPED},
+		},
+		{
+			name:     "failure skips only dependents",
+			deps:     [][]int{nil, nil, {0}, {1}, {2, 3}},
+			fail:     []int{0},
+			expected: []components.JobState{components.JOB_FAILED, components.JOB_SUCCEEDED, components.JOB_SKIPPED, components.JOB_SUCCEEDED, components.JOB_SKIPPED},
+		},
+		{
+			name:     "cycle is skipped",
+			deps:     [][]int{nil, {0, 2}, {1}},
+			expected: []components.JobState{components.JOB_SUCCEEDED, components.JOB_SKIPPED, components.JOB_SKIPPED},
+		},
+		{
+			name:     "out of range dependency ignored",
+			deps:     [][]int{{5}},
+			expected: []components.JobState{components.JOB_SUCCEEDED},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fail := components.ToSet(test.fail...)
+
+			got := components.RunGraph(test.deps, func(job int) bool {
+				_, failed := fail[job]
+				return !failed
+			})
+
+			if len(got) == 0 && len(test.expected) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("RunGraph() = %v, expected %v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestRunGraph_Order(t *testing.T) {
+	// 0 and 1 are independent and run together; 2 waits for both.
+	deps := [][]int{nil, nil, {0, 1}}
+
+	var (
+		mu      sync.Mutex
+		order   []int
+		running int
+		peak    int
+	)
+
+	components.RunGraph(deps, func(job int) bool {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		order = append(order, job)
+		mu.Unlock()
+		return true
+	})
+
+	if peak < 2 {
+		t.Error("independent jobs did not run concurrently")
+	}
+	if len(order) != 3 || order[2] != 2 {
+		t.Errorf("job 2 ran before its dependencies: order %v", order)
+	}
+}
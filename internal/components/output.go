@@ -0,0 +1,228 @@
+package components
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// PrefixWriter buffers output into lines and writes each complete line to out with a prefix,
+// so that output from concurrent processes can be attributed.
+type PrefixWriter struct {
+	out    io.Writer
+	tee    io.Writer
+	prefix []byte
+	buf    []byte
+	mu     sync.Mutex
+}
+
+func NewPrefixWriter(out io.Writer, prefix string) *PrefixWriter {
+	return &PrefixWriter{out: out, prefix: []byte(prefix)}
+}
+
+// WithTee also writes each line, without the prefix, to w.
+func (p *PrefixWriter) WithTee(w io.Writer) *PrefixWriter {
+	p.tee = w
+	return p
+}
+
+// Write buffers b, writing out any complete lines.
+func (p *PrefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf = append(p.buf, b...)
+	for {
+		idx := bytes.IndexByte(p.buf, '\n')
+		if idx == -1 {
+			break
+		}
+
+		err := p.writeLine(p.buf[:idx+1])
+		p.buf = p.buf[idx+1:]
+		if err != nil {
+			return len(b), err
+		}
+	}
+
+	return len(b), nil
+}
+
+// Flush writes out any buffered partial line, terminated with a newline.
+func (p *PrefixWriter) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.buf) == 0 {
+		return nil
+	}
+
+	line := append(p.buf, '\n')
+	p.buf = nil
+
+	return p.writeLine(line)
+}
+
+func (p *PrefixWriter) writeLine(line []byte) error {
+	_, err := p.out.Write(append(append([]byte{}, p.prefix...), line...))
+	if err != nil {
+		return err
+	}
+
+	if p.tee != nil {
+		_, err = p.tee.Write(line)
+	}
+
+	return err
+}
+
+// Capture is a writer that retains the most recent output written to it, up to a fixed size.
+type Capture struct {
+	limit int
+	buf   []byte
+	mu    sync.Mutex
+}
+
+// NewCapture returns a Capture that retains at most limitKB kilobytes of output.
+func NewCapture(limitKB uint) *Capture {
+	return &Capture{limit: int(limitKB) * 1024}
+}
+
+// Write appends b, discarding the oldest output beyond the limit.
+func (c *Capture) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.buf = append(c.buf, b...)
+	if over := len(c.buf) - c.limit; over > 0 {
+		c.buf = c.buf[over:]
+	}
+
+	return len(b), nil
+}
+
+// Reset discards all captured output.
+func (c *Capture) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buf = nil
+}
+
+// String returns the captured output.
+func (c *Capture) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return string(c.buf)
+}
+
+// RotatingFile is an append-only log file that is rotated once it exceeds a maximum size,
+// keeping a fixed number of backups named path.1 (newest) to path.N (oldest).
+type RotatingFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+	mu       sync.Mutex
+}
+
+// NewRotatingFile opens or creates the log file at path, creating parent directories as
+// needed. The file is rotated once it exceeds maxSizeKB kilobytes, keeping maxFiles backups.
+// A zero maxSizeKB never rotates; a zero maxFiles truncates the file on rotation.
+func NewRotatingFile(path string, maxSizeKB, maxFiles uint) (*RotatingFile, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	f := &RotatingFile{path: path, maxSize: int64(maxSizeKB) * 1024, maxFiles: int(maxFiles)}
+
+	err = f.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Write appends b to the file, rotating it first if b would exceed the maximum size.
+func (f *RotatingFile) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(b)) > f.maxSize {
+		err := f.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(b)
+	f.size += int64(n)
+
+	return n, err
+}
+
+// Close closes the underlying file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+
+	return err
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+
+	return nil
+}
+
+func (f *RotatingFile) rotate() error {
+	err := f.file.Close()
+	if err != nil {
+		return err
+	}
+
+	if f.maxFiles == 0 {
+		err = os.Truncate(f.path, 0)
+	} else {
+		for i := f.maxFiles - 1; i > 0; i-- {
+			err = os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		err = os.Rename(f.path, f.path+".1")
+	}
+	if err != nil {
+		return err
+	}
+
+	return f.open()
+}
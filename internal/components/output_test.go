@@ -0,0 +1,155 @@
+package components_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dimmerz92/eavesdrop/v2/internal/components"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	tests := []struct {
+		name        string
+		writes      []string
+		flush       bool
+		expected    string
+		expectedTee string
+	}{
+		{name: "single line", writes: []string{"hello\n"}, expected: "[w] hello\n", expectedTee: "hello\n"},
+		{name: "multiple lines in one write", writes: []string{"a\nb\n"}, expected: "[w] a\n[w] b\n", expectedTee: "a\nb\n"},
+		{name: "line split across writes", writes: []string{"hel", "lo\nwor", "ld\n"}, expected: "[w] hello\n[w] world\n", expectedTee: "hello\nworld\n"},
+		{name: "partial line buffered", writes: []string{"hello"}, expected: "", expectedTee: ""},
+		{name: "partial line flushed", writes: []string{"hello"}, flush: true, expected: "[w] hello\n", expectedTee: "hello\n"},
+		{name: "flush with nothing buffered", writes: []string{"hello\n"}, flush: true, expected: "[w] hello\n", expectedTee: "hello\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out, tee bytes.Buffer
+			w := components.NewPrefixWriter(&out, "[w] ").WithTee(&tee)
+
+			for _, write := range test.writes {
+				if n, err := w.Write([]byte(write)); err != nil || n != len(write) {
+					t.Fatalf("Write() = %d, %v, expected %d, nil", n, err, len(write))
+				}
+			}
+
+			if test.flush {
+				if err := w.Flush(); err != nil {
+					t.Fatalf("Flush() = %v", err)
+				}
+			}
+
+			if got := out.String(); got != test.expected {
+				t.Errorf("output = %q, expected %q", got, test.expected)
+			}
+
+			if got := tee.String(); got != test.expectedTee {
+				t.Errorf("tee = %q, expected %q", got, test.expectedTee)
+			}
+		})
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	line := bytes.Repeat([]byte("x"), 600)
+
+	tests := []struct {
+		name          string
+		maxFiles      uint
+		writes        int
+		expectedFiles []string
+	}{
+		{name: "under max size not rotated", maxFiles: 2, writes: 1, expectedFiles: []string{"out.log"}},
+		{name: "over max size rotated", maxFiles: 2, writes: 2, expectedFiles: []string{"out.log", "out.log.1"}},
+		{name: "backups capped at max files", maxFiles: 2, writes: 5, expectedFiles: []string{"out.log", "out.log.1", "out.log.2"}},
+		{name: "zero max files truncates", maxFiles: 0, writes: 3, expectedFiles: []string{"out.log"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "logs", "out.log")
+
+			f, err := components.NewRotatingFile(path, 1, test.maxFiles)
+			if err != nil {
+				t.Fatalf("NewRotatingFile() = %v", err)
+			}
+			defer f.Close()
+
+			for range test.writes {
+				if _, err := f.Write(line); err != nil {
+					t.Fatalf("Write() = %v", err)
+				}
+			}
+
+			entries, err := os.ReadDir(filepath.Join(dir, "logs"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(entries) != len(test.expectedFiles) {
+				t.Fatalf("found %d file(s), expected %v", len(entries), test.expectedFiles)
+			}
+
+			for i, entry := range entries {
+				if entry.Name() != test.expectedFiles[i] {
+					t.Errorf("file %d = %q, expected %q", i, entry.Name(), test.expectedFiles[i])
+				}
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size() != int64(len(line)) {
+				t.Errorf("current log size = %d, expected %d", info.Size(), len(line))
+			}
+		})
+	}
+
+	t.Run("write after close fails", func(t *testing.T) {
+		f, err := components.NewRotatingFile(filepath.Join(t.TempDir(), "out.log"), 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		if _, err := f.Write(line); err == nil {
+			t.Error("Write() after Close() = nil, expected error")
+		}
+	})
+}
+
+func TestCapture(t *testing.T) {
+	tests := []struct {
+		name     string
+		writes   []string
+		reset    bool
+		expected string
+	}{
+		{name: "retains output", writes: []string{"hello ", "world"}, expected: "hello world"},
+		{name: "reset discards output", writes: []string{"hello"}, reset: true, expected: ""},
+		{name: "keeps most recent output beyond limit", writes: []string{strings.Repeat("a", 1024), "tail"}, expected: strings.Repeat("a", 1020) + "tail"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := components.NewCapture(1)
+			for _, write := range test.writes {
+				c.Write([]byte(write))
+			}
+
+			if test.reset {
+				c.Reset()
+			}
+
+			if got := c.String(); got != test.expected {
+				t.Errorf("String() = %q, expected %q", got, test.expected)
+			}
+		})
+	}
+}
@@ -2,7 +2,11 @@ package components
 
 import (
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -10,10 +14,13 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/andybalholm/brotli"
 	"github.com/fatih/color"
 	"github.com/hashicorp/go-retryablehttp"
 )
@@ -24,14 +31,82 @@ eventSource.onmessage = (event) => {
 	if (event.data === "refresh") window.location.reload();
 }
 
-eventSource.onerror = (error) => console.error("eavesdrop sse error:", error);`
+eventSource.addEventListener("overlay", (event) => eavesdropOverlay(JSON.parse(event.data)));
+
+eventSource.addEventListener("css", (event) => eavesdropSwapCSS(event.data));
+
+eventSource.onerror = (error) => console.error("eavesdrop sse error:", error);
+
+function eavesdropSwapCSS(path) {
+	const name = path.split(/[\\/]/).pop();
+	let swapped = false;
+
+	for (const link of document.querySelectorAll('link[rel="stylesheet"]')) {
+		const url = new URL(link.href, window.location.href);
+		if (url.origin !== window.location.origin || url.pathname.split("/").pop() !== name) continue;
+
+		url.searchParams.set("eavesdrop", Date.now());
+		link.href = url.toString();
+		swapped = true;
+	}
+
+	if (!swapped) window.location.reload();
+}
+
+function eavesdropOverlay(errors) {
+	document.getElementById("eavesdrop-overlay")?.remove();
+	if (!errors || errors.length === 0) return;
+
+	const overlay = document.createElement("div");
+	overlay.id = "eavesdrop-overlay";
+	overlay.style.cssText = "position:fixed;inset:0;z-index:2147483647;overflow:auto;padding:2rem;" +
+		"background:rgba(20,20,20,0.95);color:#eee;font:14px/1.5 monospace;";
+
+	for (const error of errors) {
+		const title = document.createElement("h2");
+		title.style.cssText = "color:#ff6b6b;margin:0 0 1rem;font-size:16px;";
+		title.textContent = error.source + ": " + error.title;
+
+		const output = document.createElement("pre");
+		output.style.cssText = "white-space:pre-wrap;margin:0 0 2rem;";
+		output.textContent = error.output;
+
+		overlay.append(title, output);
+	}
+
+	document.body.append(overlay);
+}`
+
+// HOLDING_PAGE is served to browser navigations while the app is unreachable, e.g. between
+// stopping the old service and the new one binding its port. It retries every second and the
+// injected script reloads it as soon as a refresh is triggered.
+const HOLDING_PAGE = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Restarting…</title>
+</head>
+<body style="display:flex;align-items:center;justify-content:center;height:100vh;margin:0;font-family:sans-serif;color:#555;">
+<p>Restarting…</p>
+%s
+<script>setTimeout(() => window.location.reload(), 1000);</script>
+</body>
+</html>`
+
+// OverlayError is a failure displayed in the browser overlay.
+type OverlayError struct {
+	Source string `json:"source"`
+	Title  string `json:"title"`
+	Output string `json:"output"`
+}
 
 type Proxy struct {
 	ctx         context.Context
 	appPort     uint16
 	proxyPort   uint16
 	mu          sync.Mutex
-	subscribers map[chan struct{}]struct{}
+	subscribers map[chan string]struct{}
+	errors      map[string]OverlayError
 }
 
 func NewProxy(ctx context.Context, appPort, proxyPort uint16) (*Proxy, error) {
@@ -43,11 +118,16 @@ func NewProxy(ctx context.Context, appPort, proxyPort uint16) (*Proxy, error) {
 		ctx:         ctx,
 		appPort:     appPort,
 		proxyPort:   proxyPort,
-		subscribers: make(map[chan struct{}]struct{}),
+		subscribers: make(map[chan string]struct{}),
+		errors:      make(map[string]OverlayError),
 	}
 
+	// keep retries short; browser navigations fall back to the self-retrying holding page.
 	retryClient := retryablehttp.NewClient()
 	retryClient.Logger = nil
+	retryClient.RetryMax = 3
+	retryClient.RetryWaitMin = 100 * time.Millisecond
+	retryClient.RetryWaitMax = 500 * time.Millisecond
 
 	target, _ := url.Parse(fmt.Sprintf("http://127.0.0.1:%d", p.appPort))
 	rp := &httputil.ReverseProxy{
@@ -58,14 +138,30 @@ func NewProxy(ctx context.Context, appPort, proxyPort uint16) (*Proxy, error) {
 		},
 		Transport:      &retryablehttp.RoundTripper{Client: retryClient},
 		ModifyResponse: p.injectSSE,
+		ErrorHandler:   p.handleError,
+	}
+
+	// protocol upgrades such as WebSockets need a transport that hands back the raw connection.
+	upgrades := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.SetXForwarded()
+		},
+		Transport: http.DefaultTransport,
 		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
-			color.Red("proxy error: %v", err)
+			color.Red("proxy upgrade error: %v", err)
 			http.Error(w, err.Error(), http.StatusBadGateway)
 		},
 	}
 
 	mux := http.NewServeMux()
-	mux.Handle("/", rp)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if isUpgrade(r) {
+			upgrades.ServeHTTP(w, r)
+			return
+		}
+		rp.ServeHTTP(w, r)
+	})
 	mux.HandleFunc("/eavesdrop_sse", p.handleSSE)
 
 	server := &http.Server{
@@ -98,11 +194,60 @@ func NewProxy(ctx context.Context, appPort, proxyPort uint16) (*Proxy, error) {
 }
 
 func (p *Proxy) RefreshBrowser() {
+	p.broadcast("data: refresh\n\n")
+}
+
+// RefreshCSS makes every connected page reload the stylesheets linked from the file at path,
+// matched by file name, without reloading the page. Pages linking no such stylesheet reload.
+func (p *Proxy) RefreshCSS(path string) {
+	p.broadcast(fmt.Sprintf("event: css\ndata: %s\n\n", filepath.ToSlash(path)))
+}
+
+// ShowError displays output in an overlay on every connected page, and on every page served
+// until ClearError is called for source. A later error for the same source replaces it.
+func (p *Proxy) ShowError(source, title, output string) {
+	p.mu.Lock()
+	p.errors[source] = OverlayError{Source: source, Title: title, Output: output}
+	p.mu.Unlock()
+
+	p.broadcast(fmt.Sprintf("event: overlay\ndata: %s\n\n", p.overlayJSON()))
+}
+
+// ClearError removes the error for source from the overlay, if one is shown.
+func (p *Proxy) ClearError(source string) {
+	p.mu.Lock()
+	_, ok := p.errors[source]
+	delete(p.errors, source)
+	p.mu.Unlock()
+
+	if ok {
+		p.broadcast(fmt.Sprintf("event: overlay\ndata: %s\n\n", p.overlayJSON()))
+	}
+}
+
+// overlayJSON returns the current errors as a JSON array, sorted by source.
+func (p *Proxy) overlayJSON() []byte {
+	p.mu.Lock()
+	overlay := make([]OverlayError, 0, len(p.errors))
+	for _, err := range p.errors {
+		overlay = append(overlay, err)
+	}
+	p.mu.Unlock()
+
+	slices.SortFunc(overlay, func(a, b OverlayError) int { return strings.Compare(a.Source, b.Source) })
+
+	data, _ := json.Marshal(overlay)
+	return data
+}
+
+// broadcast sends a server-sent event frame to every subscriber, dropping it for any that are
+// not keeping up.
+func (p *Proxy) broadcast(frame string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	for subscriber := range p.subscribers {
 		select {
-		case subscriber <- struct{}{}:
+		case subscriber <- frame:
 		default:
 		}
 	}
@@ -122,7 +267,7 @@ func (p *Proxy) handleSSE(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, "data: connected\n\n")
 	flusher.Flush()
 
-	subscriber := make(chan struct{}, 1)
+	subscriber := make(chan string, 8)
 	p.mu.Lock()
 	p.subscribers[subscriber] = struct{}{}
 	p.mu.Unlock()
@@ -135,26 +280,97 @@ func (p *Proxy) handleSSE(w http.ResponseWriter, r *http.Request) {
 			close(subscriber)
 			p.mu.Unlock()
 			return
-		case <-subscriber:
-			fmt.Fprint(w, "data: refresh\n\n")
+		case frame := <-subscriber:
+			fmt.Fprint(w, frame)
 			flusher.Flush()
 		}
 	}
 }
 
+// handleError serves the holding page to browser navigations and a plain 502 to anything else,
+// such as API or XHR requests.
+func (p *Proxy) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	if !isNavigation(r) {
+		color.Red("proxy error: %v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprintf(w, HOLDING_PAGE, p.script())
+}
+
+// isNavigation reports whether r is a browser loading a page, rather than a script fetching data.
+func isNavigation(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+
+	if mode := r.Header.Get("Sec-Fetch-Mode"); mode != "" {
+		return mode == "navigate"
+	}
+
+	return r.Header.Get("X-Requested-With") == "" && strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// isUpgrade reports whether r asks to switch protocols, e.g. to a WebSocket.
+func isUpgrade(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+
+	for _, value := range r.Header.Values("Connection") {
+		for token := range strings.SplitSeq(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// script returns the live reload script tag injected into HTML pages.
+func (p *Proxy) script() string {
+	return fmt.Sprintf("<script>%s\neavesdropOverlay(%s);</script>", SSE_SCRIPT, p.overlayJSON())
+}
+
+// injectSSE adds the live reload script to HTML responses. Compressed bodies are decoded first
+// and sent on uncompressed; bodies in an unsupported encoding are passed through unchanged.
 func (p *Proxy) injectSSE(resp *http.Response) error {
 	if !strings.Contains(resp.Header.Get("Content-Type"), "text/html") {
 		return nil
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if !slices.Contains(supportedEncodings, encoding) {
+		return nil
+	}
+
+	raw, err := io.ReadAll(resp.Body)
 	resp.Body.Close()
 	if err != nil {
 		return err
 	}
 
+	body, err := decode(encoding, raw)
+	if err != nil {
+		color.Red("proxy error: failed to decode %s response: %v", encoding, err)
+		resp.Body = io.NopCloser(bytes.NewReader(raw))
+		return nil
+	}
+
 	if idx := strings.LastIndex(string(body), "</body>"); idx != -1 {
-		body = fmt.Appendf(nil, "%s<script>%s</script>%s", body[:idx], SSE_SCRIPT, body[idx:])
+		body = fmt.Appendf(nil, "%s%s%s", body[:idx], p.script(), body[idx:])
+	}
+
+	if encoding != "" && encoding != "identity" {
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("ETag") // the representation no longer matches the upstream one
+		resp.Uncompressed = true
 	}
 
 	resp.Body = io.NopCloser(bytes.NewReader(body))
@@ -163,3 +379,37 @@ func (p *Proxy) injectSSE(resp *http.Response) error {
 
 	return nil
 }
+
+// supportedEncodings are the Content-Encoding values injectSSE can decode.
+var supportedEncodings = []string{"", "identity", "gzip", "x-gzip", "deflate", "br"}
+
+// decode returns data decoded from the given Content-Encoding.
+func decode(encoding string, data []byte) ([]byte, error) {
+	var r io.Reader
+	switch encoding {
+	case "gzip", "x-gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	case "deflate":
+		// deflate should be zlib-wrapped, but some servers send raw deflate streams.
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			fr := flate.NewReader(bytes.NewReader(data))
+			defer fr.Close()
+			r = fr
+		} else {
+			defer zr.Close()
+			r = zr
+		}
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(data))
+	default:
+		return data, nil
+	}
+
+	return io.ReadAll(r)
+}
@@ -2,6 +2,10 @@ package components_test
 
 import (
 	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
 	"fmt"
 	"io"
@@ -13,7 +17,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/andybalholm/brotli"
 	"github.com/dimmerz92/eavesdrop/v2/internal/components"
+	"github.com/gorilla/websocket"
 )
 
 func freePort(t *testing.T) uint16 {
@@ -222,3 +228,419 @@ func TestProxy_SSE(t *testing.T) {
 		})
 	}
 }
+
+func TestProxy_Overlay(t *testing.T) {
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><body>hello</body></html>")
+	}))
+	defer app.Close()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	proxyPort := freePort(t)
+	p, _ := components.NewProxy(ctx, appPort(app), proxyPort)
+
+	get := func(t *testing.T) string {
+		t.Helper()
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/", proxyPort))
+		if err != nil {
+			t.Fatalf("proxy GET: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/eavesdrop_sse", proxyPort))
+	if err != nil {
+		t.Fatalf("SSE connect: %v", err)
+	}
+	defer resp.Body.Close()
+
+	frames := make(chan string, 10)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		var frame []string
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				frame = append(frame, line)
+				continue
+			}
+			frames <- strings.Join(frame, "\n")
+			frame = nil
+		}
+	}()
+
+	awaitFrame := func(t *testing.T, prefix string) string {
+		t.Helper()
+		for {
+			select {
+			case frame := <-frames:
+				if strings.HasPrefix(frame, prefix) {
+					return frame
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("timeout waiting for %q frame", prefix)
+				return ""
+			}
+		}
+	}
+
+	awaitFrame(t, "data: connected")
+
+	t.Run("no errors injects empty overlay", func(t *testing.T) {
+		if body := get(t); !strings.Contains(body, "eavesdropOverlay([]);") {
+			t.Errorf("body does not contain empty overlay\nbody: %s", body)
+		}
+	})
+
+	t.Run("ShowError pushes and injects overlay", func(t *testing.T) {
+		p.ShowError("api", "go build", "main.go:1: syntax error <html>")
+
+		frame := awaitFrame(t, "event: overlay")
+		if !strings.Contains(frame, `"source":"api"`) || !strings.Contains(frame, "syntax error") {
+			t.Errorf("overlay frame missing error: %s", frame)
+		}
+
+		body := get(t)
+		if !strings.Contains(body, "syntax error") {
+			t.Errorf("body does not contain error output\nbody: %s", body)
+		}
+		if strings.Contains(body, "syntax error <html>") {
+			t.Errorf("error output not escaped\nbody: %s", body)
+		}
+	})
+
+	t.Run("ClearError pushes and removes overlay", func(t *testing.T) {
+		p.ClearError("api")
+
+		if frame := awaitFrame(t, "event: overlay"); !strings.HasSuffix(frame, "data: []") {
+			t.Errorf("overlay frame = %q, expected empty overlay", frame)
+		}
+
+		if body := get(t); strings.Contains(body, "syntax error") {
+			t.Errorf("body still contains cleared error\nbody: %s", body)
+		}
+	})
+}
+
+func TestProxy_HoldingPage(t *testing.T) {
+	tests := []struct {
+		name                string
+		method              string
+		headers             map[string]string
+		expectedStatus      int
+		expectedContains    string
+		expectedNotContains string
+	}{
+		{
+			name:             "navigation gets holding page",
+			method:           http.MethodGet,
+			headers:          map[string]string{"Sec-Fetch-Mode": "navigate", "Accept": "text/html"},
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedContains: "EventSource",
+		},
+		{
+			name:             "html accept without fetch metadata gets holding page",
+			method:           http.MethodGet,
+			headers:          map[string]string{"Accept": "text/html,application/xhtml+xml"},
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedContains: "Restarting",
+		},
+		{
+			name:                "fetch request gets 502",
+			method:              http.MethodGet,
+			headers:             map[string]string{"Sec-Fetch-Mode": "cors", "Accept": "text/html"},
+			expectedStatus:      http.StatusBadGateway,
+			expectedNotContains: "EventSource",
+		},
+		{
+			name:                "xhr gets 502",
+			method:              http.MethodGet,
+			headers:             map[string]string{"X-Requested-With": "XMLHttpRequest", "Accept": "text/html"},
+			expectedStatus:      http.StatusBadGateway,
+			expectedNotContains: "EventSource",
+		},
+		{
+			name:                "json request gets 502",
+			method:              http.MethodGet,
+			headers:             map[string]string{"Accept": "application/json"},
+			expectedStatus:      http.StatusBadGateway,
+			expectedNotContains: "EventSource",
+		},
+		{
+			name:                "post gets 502",
+			method:              http.MethodPost,
+			headers:             map[string]string{"Accept": "text/html"},
+			expectedStatus:      http.StatusBadGateway,
+			expectedNotContains: "EventSource",
+		},
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	proxyPort := freePort(t)
+	_, _ = components.NewProxy(ctx, freePort(t), proxyPort)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, fmt.Sprintf("http://localhost:%d/", proxyPort), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, value := range test.headers {
+				req.Header.Set(key, value)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("proxy request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != test.expectedStatus {
+				t.Errorf("status = %d, expected %d", resp.StatusCode, test.expectedStatus)
+			}
+
+			body, _ := io.ReadAll(resp.Body)
+			bodyStr := string(body)
+
+			if test.expectedContains != "" && !strings.Contains(bodyStr, test.expectedContains) {
+				t.Errorf("body does not contain %q\nbody: %s", test.expectedContains, bodyStr)
+			}
+
+			if test.expectedNotContains != "" && strings.Contains(bodyStr, test.expectedNotContains) {
+				t.Errorf("body unexpectedly contains %q\nbody: %s", test.expectedNotContains, bodyStr)
+			}
+		})
+	}
+}
+
+func TestProxy_CompressedHTML(t *testing.T) {
+	const page = "<html><body>hello</body></html>"
+
+	compress := func(newWriter func(io.Writer) io.WriteCloser) []byte {
+		var buf bytes.Buffer
+		w := newWriter(&buf)
+		io.WriteString(w, page)
+		w.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name             string
+		encoding         string
+		body             []byte
+		expectedEncoding string
+		expectedInjected bool
+	}{
+		{
+			name:             "gzip",
+			encoding:         "gzip",
+			body:             compress(func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }),
+			expectedInjected: true,
+		},
+		{
+			name:             "zlib deflate",
+			encoding:         "deflate",
+			body:             compress(func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }),
+			expectedInjected: true,
+		},
+		{
+			name:     "raw deflate",
+			encoding: "deflate",
+			body: compress(func(w io.Writer) io.WriteCloser {
+				fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+				return fw
+			}),
+			expectedInjected: true,
+		},
+		{
+			name:             "brotli",
+			encoding:         "br",
+			body:             compress(func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) }),
+			expectedInjected: true,
+		},
+		{
+			name:             "unsupported encoding passes through",
+			encoding:         "zstd",
+			body:             []byte("opaque"),
+			expectedEncoding: "zstd",
+		},
+		{
+			name:             "corrupt body passes through",
+			encoding:         "gzip",
+			body:             []byte("not gzip"),
+			expectedEncoding: "gzip",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.Header().Set("Content-Encoding", test.encoding)
+				w.Header().Set("ETag", `"upstream"`)
+				w.Write(test.body)
+			}))
+			defer app.Close()
+
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+
+			proxyPort := freePort(t)
+			_, _ = components.NewProxy(ctx, appPort(app), proxyPort)
+
+			req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost:%d/", proxyPort), nil)
+			req.Header.Set("Accept-Encoding", "gzip, deflate, br") // stops the client decoding transparently
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("proxy GET: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if got := resp.Header.Get("Content-Encoding"); got != test.expectedEncoding {
+				t.Errorf("Content-Encoding = %q, expected %q", got, test.expectedEncoding)
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("read body: %v", err)
+			}
+
+			if !test.expectedInjected {
+				if !bytes.Equal(body, test.body) {
+					t.Errorf("body = %q, expected unchanged %q", body, test.body)
+				}
+				return
+			}
+
+			if resp.Header.Get("ETag") != "" {
+				t.Error("ETag was not removed from modified response")
+			}
+
+			if !strings.Contains(string(body), "EventSource") || !strings.HasPrefix(string(body), "<html><body>hello") {
+				t.Errorf("body is not decoded html with injected script\nbody: %s", body)
+			}
+
+			if resp.ContentLength != int64(len(body)) {
+				t.Errorf("Content-Length = %d, expected %d", resp.ContentLength, len(body))
+			}
+		})
+	}
+}
+
+func TestProxy_WebSocket(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(messageType, append([]byte("echo: "), message...)); err != nil {
+				return
+			}
+		}
+	}))
+	defer app.Close()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	proxyPort := freePort(t)
+	_, _ = components.NewProxy(ctx, appPort(app), proxyPort)
+
+	conn, resp, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://localhost:%d/ws", proxyPort), nil)
+	if err != nil {
+		t.Fatalf("failed to dial websocket through proxy: %v", err)
+	}
+	defer conn.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("status = %d, expected %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+
+	tests := []struct {
+		messageType int
+		message     string
+	}{
+		{websocket.TextMessage, "hello"},
+		{websocket.TextMessage, "world"},
+		{websocket.BinaryMessage, "\x00\x01"},
+	}
+
+	for _, test := range tests {
+		conn.NetConn().SetDeadline(time.Now().Add(2 * time.Second))
+
+		if err := conn.WriteMessage(test.messageType, []byte(test.message)); err != nil {
+			t.Fatalf("failed to write message: %v", err)
+		}
+
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read message: %v", err)
+		}
+
+		if messageType != test.messageType {
+			t.Errorf("message type = %d, expected %d", messageType, test.messageType)
+		}
+
+		if expected := "echo: " + test.message; string(message) != expected {
+			t.Errorf("message = %q, expected %q", message, expected)
+		}
+	}
+}
+
+func TestProxy_RefreshCSS(t *testing.T) {
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer app.Close()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	proxyPort := freePort(t)
+	p, _ := components.NewProxy(ctx, appPort(app), proxyPort)
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/eavesdrop_sse", proxyPort))
+	if err != nil {
+		t.Fatalf("SSE connect: %v", err)
+	}
+	defer resp.Body.Close()
+
+	lines := make(chan string, 10)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				lines <- line
+			}
+		}
+	}()
+
+	expected := []string{"data: connected", "event: css", "data: static/app.css"}
+	for i, want := range expected {
+		if i == 1 {
+			p.RefreshCSS("static/app.css")
+		}
+
+		select {
+		case line := <-lines:
+			if line != want {
+				t.Errorf("line = %q, expected %q", line, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %q", want)
+		}
+	}
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"fmt"
 	"path/filepath"
+	"slices"
+
+	"github.com/dimmerz92/eavesdrop/v2"
 )
 
 const (
@@ -12,92 +15,264 @@ const (
 	DefaultRefreshDelay           = 100
 	DefaultServiceShutdownTimeout = 5000
 	DefaultTaskRunTimeout         = 2000
+	DefaultPollInterval           = ev.DefaultPollInterval
+	DefaultReadinessTimeout       = 10000
+	DefaultReadinessInterval      = 100
+	DefaultRestartMax             = 5
+	DefaultRestartBackoff         = 500
+	DefaultRestartMaxBackoff      = 30000
+	DefaultLogMaxSize             = 1024
+	DefaultLogMaxFiles            = 3
+)
+
+const (
+	BackendFsnotify = "fsnotify"
+	BackendPoll     = "poll"
+)
+
+const (
+	RefreshReload = "reload"
+	RefreshCSS    = "css"
+)
+
+const (
+	LockGlobal  = "global"  // runs are serialised with every other watcher using the global lock
+	LockWatcher = "watcher" // runs are only serialised with the watcher's own runs
+	// any other lock name is a group, serialising runs with the other watchers in that group
+)
+
+const (
+	RunModeQueue  = "queue"  // a change waits for the running tasks to finish
+	RunModeLatest = "latest" // a change cancels the running tasks and starts over
 )
 
 type Config struct {
 	RootDir       string          `json:"root_dir" toml:"root_dir" yaml:"root_dir"`
+	Backend       string          `json:"backend" toml:"backend" yaml:"backend"`
+	PollInterval  uint            `json:"poll_interval" toml:"poll_interval" yaml:"poll_interval"`
 	Tmp           bool            `json:"tmp" toml:"tmp" yaml:"tmp"`
 	CleanupTmp    bool            `json:"cleanup_tmp" toml:"cleanup_tmp" yaml:"cleanup_tmp"`
+	EnvFile       []string        `json:"env_file" toml:"env_file" yaml:"env_file"`
 	GlobalExclude ExcluderConfig  `json:"global_exclude" toml:"global_exclude" yaml:"global_exclude"`
 	Watchers      []WatcherConfig `json:"watchers" toml:"watchers" yaml:"watchers"`
 	Proxy         ProxyConfig     `json:"proxy" toml:"proxy" yaml:"proxy"`
+
+	// Env holds the variables loaded from EnvFile, passed to every task and service.
+	Env map[string]string `json:"-" toml:"-" yaml:"-"`
 }
 
 type ExcluderConfig struct {
-	Ops   []string `json:"ops" toml:"ops" yaml:"ops"`
-	Dirs  []string `json:"dirs" toml:"dirs" yaml:"dirs"`
-	Files []string `json:"files" toml:"files" yaml:"files"`
-	Regex []string `json:"regex" toml:"regex" yaml:"regex"`
+	Ops       []string `json:"ops" toml:"ops" yaml:"ops"`
+	Dirs      []string `json:"dirs" toml:"dirs" yaml:"dirs"`
+	Files     []string `json:"files" toml:"files" yaml:"files"`
+	Regex     []string `json:"regex" toml:"regex" yaml:"regex"`
+	Globs     []string `json:"globs" toml:"globs" yaml:"globs"`
+	Gitignore bool     `json:"gitignore" toml:"gitignore" yaml:"gitignore"`
 }
 
 type WatcherConfig struct {
-	Name           string         `json:"name" toml:"name" yaml:"name"`
-	Filetypes      []string       `json:"filetypes" toml:"filetypes" yaml:"filetypes"`
-	Dirs           []string       `json:"dirs" toml:"dirs" yaml:"dirs"`
-	Files          []string       `json:"files" toml:"files" yaml:"files"`
-	Exclude        ExcluderConfig `json:"exclude" toml:"exclude" yaml:"exclude"`
-	Shell          ShellConfig    `json:"shell" toml:"shell" yaml:"shell"`
-	RunOnStart     bool           `json:"run_on_start" toml:"run_on_start" yaml:"run_on_start"`
-	TriggerRefresh bool           `json:"trigger_refresh" toml:"trigger_refresh" yaml:"trigger_refresh"`
-	RefreshDelay   uint           `json:"refresh_delay" toml:"refresh_delay" yaml:"refresh_delay"`
+	Name             string            `json:"name" toml:"name" yaml:"name"`
+	Filetypes        []string          `json:"filetypes" toml:"filetypes" yaml:"filetypes"`
+	Dirs             []string          `json:"dirs" toml:"dirs" yaml:"dirs"`
+	Files            []string          `json:"files" toml:"files" yaml:"files"`
+	Globs            []string          `json:"globs" toml:"globs" yaml:"globs"`
+	Exclude          ExcluderConfig    `json:"exclude" toml:"exclude" yaml:"exclude"`
+	Shell            ShellConfig       `json:"shell" toml:"shell" yaml:"shell"`
+	Output           OutputConfig      `json:"output" toml:"output" yaml:"output"`
+	RunOnStart       bool              `json:"run_on_start" toml:"run_on_start" yaml:"run_on_start"`
+	RunMode          string            `json:"run_mode" toml:"run_mode" yaml:"run_mode"`
+	Lock             string            `json:"lock" toml:"lock" yaml:"lock"`
+	OnSuccess        []string          `json:"on_success" toml:"on_success" yaml:"on_success"`
+	OnFailure        []string          `json:"on_failure" toml:"on_failure" yaml:"on_failure"`
+	IgnoreOwnChanges bool              `json:"ignore_own_changes" toml:"ignore_own_changes" yaml:"ignore_own_changes"`
+	TriggerRefresh   bool              `json:"trigger_refresh" toml:"trigger_refresh" yaml:"trigger_refresh"`
+	RefreshDelay     uint              `json:"refresh_delay" toml:"refresh_delay" yaml:"refresh_delay"`
+	RefreshModes     map[string]string `json:"refresh_modes" toml:"refresh_modes" yaml:"refresh_modes"`
+}
+
+// CSSFiletypes returns the file extensions whose refresh mode is RefreshCSS.
+func (w WatcherConfig) CSSFiletypes() []string {
+	var filetypes []string
+	for ext, mode := range w.RefreshModes {
+		if mode == RefreshCSS {
+			filetypes = append(filetypes, ext)
+		}
+	}
+	slices.Sort(filetypes)
+	return filetypes
+}
+
+type OutputConfig struct {
+	Prefix      bool   `json:"prefix" toml:"prefix" yaml:"prefix"`
+	LogFile     string `json:"log_file" toml:"log_file" yaml:"log_file"`
+	LogMaxSize  uint   `json:"log_max_size" toml:"log_max_size" yaml:"log_max_size"`
+	LogMaxFiles uint   `json:"log_max_files" toml:"log_max_files" yaml:"log_max_files"`
 }
 
 type ShellConfig struct {
-	Tasks                  []string `json:"tasks" toml:"tasks" yaml:"tasks"`
-	TaskTimeout            uint     `json:"task_timeout" toml:"task_timeout" yaml:"task_timeout"`
-	Service                string   `json:"service" toml:"service" yaml:"service"`
-	ServiceShutdownTimeout uint     `json:"service_shutdown_timeout" toml:"service_shutdown_timeout" yaml:"service_shutdown_timeout"`
-	DebounceDelay          uint     `json:"debounce_delay" toml:"debounce_delay" yaml:"debounce_delay"`
+	Shell                  InterpreterConfig `json:"shell" toml:"shell" yaml:"shell"`
+	Cwd                    string            `json:"cwd" toml:"cwd" yaml:"cwd"`
+	Env                    map[string]string `json:"env" toml:"env" yaml:"env"`
+	Tasks                  []Task            `json:"tasks" toml:"tasks" yaml:"tasks"`
+	TaskTimeout            uint              `json:"task_timeout" toml:"task_timeout" yaml:"task_timeout"`
+	Service                string            `json:"service" toml:"service" yaml:"service"`
+	Services               []ServiceConfig   `json:"services" toml:"services" yaml:"services"`
+	ServiceShutdownTimeout uint              `json:"service_shutdown_timeout" toml:"service_shutdown_timeout" yaml:"service_shutdown_timeout"`
+	DebounceDelay          uint              `json:"debounce_delay" toml:"debounce_delay" yaml:"debounce_delay"`
+	Readiness              ReadinessConfig   `json:"readiness" toml:"readiness" yaml:"readiness"`
+	Restart                RestartConfig     `json:"restart" toml:"restart" yaml:"restart"`
+}
+
+// Environ returns Env in KEY=value form, sorted by key.
+func (s ShellConfig) Environ() []string {
+	return environ(s.Env)
+}
+
+// AllServices returns Service, if set, followed by Services. Service is given an empty name, and
+// services without a shutdown timeout inherit ServiceShutdownTimeout.
+func (s ShellConfig) AllServices() []ServiceConfig {
+	var services []ServiceConfig
+	if s.Service != "" {
+		services = append(services, ServiceConfig{Command: s.Service})
+	}
+	services = append(services, s.Services...)
+
+	for i := range services {
+		if services[i].ShutdownTimeout == 0 {
+			services[i].ShutdownTimeout = s.ServiceShutdownTimeout
+		}
+	}
+
+	return services
+}
+
+// ServiceConfig is a named long-running command started after a watcher's tasks complete.
+type ServiceConfig struct {
+	Name            string `json:"name" toml:"name" yaml:"name"`
+	Command         string `json:"command" toml:"command" yaml:"command"`
+	ShutdownTimeout uint   `json:"shutdown_timeout" toml:"shutdown_timeout" yaml:"shutdown_timeout"`
+}
+
+// InterpreterConfig selects the shell that runs tasks and services. An empty Path uses /bin/sh,
+// or powershell.exe or cmd.exe on Windows; an empty Flag uses the usual flag for the shell.
+type InterpreterConfig struct {
+	Path string `json:"path" toml:"path" yaml:"path"`
+	Flag string `json:"flag" toml:"flag" yaml:"flag"`
+}
+
+type ReadinessConfig struct {
+	TCP      string `json:"tcp" toml:"tcp" yaml:"tcp"`
+	HTTP     string `json:"http" toml:"http" yaml:"http"`
+	Log      string `json:"log" toml:"log" yaml:"log"`
+	Timeout  uint   `json:"timeout" toml:"timeout" yaml:"timeout"`
+	Interval uint   `json:"interval" toml:"interval" yaml:"interval"`
+}
+
+type RestartConfig struct {
+	Policy      string `json:"policy" toml:"policy" yaml:"policy"`
+	MaxRestarts uint   `json:"max_restarts" toml:"max_restarts" yaml:"max_restarts"`
+	Backoff     uint   `json:"backoff" toml:"backoff" yaml:"backoff"`
+	MaxBackoff  uint   `json:"max_backoff" toml:"max_backoff" yaml:"max_backoff"`
 }
 
 type ProxyConfig struct {
-	Enabled   bool   `json:"enabled" toml:"enabled" yaml:"enabled"`
-	AppPort   uint16 `json:"app_port" toml:"app_port" yaml:"app_port"`
-	ProxyPort uint16 `json:"proxy_port" toml:"proxy_port" yaml:"proxy_port"`
+	Enabled   bool `json:"enabled" toml:"enabled" yaml:"enabled"`
+	AppPort   Port `json:"app_port" toml:"app_port" yaml:"app_port"`
+	ProxyPort Port `json:"proxy_port" toml:"proxy_port" yaml:"proxy_port"`
 }
 
 func DefaultConfig() Config {
 	return Config{
-		RootDir: ".",
+		RootDir:      ".",
+		Backend:      BackendFsnotify,
+		PollInterval: DefaultPollInterval,
+		EnvFile:      []string{},
 		GlobalExclude: ExcluderConfig{
 			Ops:   []string{"CHMOD"},
 			Dirs:  []string{"data", "dist", "node_modules", "tmp"},
 			Files: []string{},
+			Globs: []string{},
 			Regex: []string{
 				`^\.?(\/?|\\?)(?:\w+(\/|\\))*(\.\w+)$`, // dotfiles on windows or unix at any hierarchy
 				`^.+\.sqlite$`, `^.+\.wal$`, `^.+\.shm$`,
 			},
 		},
-		Watchers: []WatcherConfig{{
-			Name:      "watcher",
-			Filetypes: []string{},
-			Dirs:      []string{},
-			Files:     []string{},
-			Exclude: ExcluderConfig{
-				Ops:   []string{},
-				Dirs:  []string{},
-				Files: []string{},
-				Regex: []string{},
-			},
-			Shell: ShellConfig{
-				Tasks:                  []string{},
-				TaskTimeout:            DefaultTaskRunTimeout,
-				Service:                "",
-				ServiceShutdownTimeout: DefaultServiceShutdownTimeout,
-				DebounceDelay:          DefaultDebounceDelay,
-			},
-			RunOnStart:     true,
-			TriggerRefresh: false,
-			RefreshDelay:   DefaultRefreshDelay,
-		}},
+		Watchers: []WatcherConfig{DefaultWatcherConfig("watcher")},
 		Proxy: ProxyConfig{
 			Enabled:   false,
-			AppPort:   DefaultAppPort,
-			ProxyPort: DefaultProxyPort,
+			AppPort:   NewPort(DefaultAppPort),
+			ProxyPort: NewPort(DefaultProxyPort),
 		},
 	}
 }
 
+// DefaultWatcherConfig returns a watcher named name with every setting at its default. Watchers
+// read from a config file are layered over it, so omitted settings keep these values.
+func DefaultWatcherConfig(name string) WatcherConfig {
+	return WatcherConfig{
+		Name:      name,
+		Filetypes: []string{},
+		Dirs:      []string{},
+		Files:     []string{},
+		Globs:     []string{},
+		Exclude: ExcluderConfig{
+			Ops:   []string{},
+			Dirs:  []string{},
+			Files: []string{},
+			Regex: []string{},
+			Globs: []string{},
+		},
+		Shell: ShellConfig{
+			Env:                    map[string]string{},
+			Tasks:                  []Task{},
+			TaskTimeout:            DefaultTaskRunTimeout,
+			Service:                "",
+			Services:               []ServiceConfig{},
+			ServiceShutdownTimeout: DefaultServiceShutdownTimeout,
+			DebounceDelay:          DefaultDebounceDelay,
+			Readiness: ReadinessConfig{
+				Timeout:  DefaultReadinessTimeout,
+				Interval: DefaultReadinessInterval,
+			},
+			Restart: RestartConfig{
+				Policy:      "never",
+				MaxRestarts: DefaultRestartMax,
+				Backoff:     DefaultRestartBackoff,
+				MaxBackoff:  DefaultRestartMaxBackoff,
+			},
+		},
+		Output: OutputConfig{
+			Prefix:      true,
+			LogFile:     "",
+			LogMaxSize:  DefaultLogMaxSize,
+			LogMaxFiles: DefaultLogMaxFiles,
+		},
+		RunOnStart:       true,
+		RunMode:          RunModeQueue,
+		Lock:             LockGlobal,
+		OnSuccess:        []string{},
+		OnFailure:        []string{},
+		IgnoreOwnChanges: true,
+		TriggerRefresh:   false,
+		RefreshDelay:     DefaultRefreshDelay,
+		RefreshModes:     map[string]string{},
+	}
+}
+
+// mergeWatchers decodes each raw watcher from a config file over DefaultWatcherConfig.
+func mergeWatchers[T any](raw []T, decode func(T, *WatcherConfig) error) ([]WatcherConfig, error) {
+	watchers := make([]WatcherConfig, 0, len(raw))
+	for i, r := range raw {
+		watcher := DefaultWatcherConfig("")
+		err := decode(r, &watcher)
+		if err != nil {
+			return nil, fmt.Errorf("watchers[%d]: %w", i, err)
+		}
+		watchers = append(watchers, watcher)
+	}
+	return watchers, nil
+}
+
 func GetConfig(path string) (Config, error) {
 	var (
 		err    error
@@ -118,7 +293,11 @@ func GetConfig(path string) (Config, error) {
 		err = fmt.Errorf("please use .json, .yaml, or .toml, not %s", ext)
 	}
 
-	return config, err
+	if err != nil {
+		return config, err
+	}
+
+	return config, config.expandEnv()
 }
 
 func GenerateConfig(path, ext string) error {
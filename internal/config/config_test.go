@@ -1,17 +1,26 @@
 package config_test
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/dimmerz92/eavesdrop/v2/internal/config"
 )
 
 func generateConfig() config.Config {
-	config := config.DefaultConfig()
-	config.Watchers[0].Filetypes = []string{".go"}
-	config.Watchers[0].Shell.Tasks = []string{"echo hello"}
+	cfg := config.DefaultConfig()
+	cfg.Watchers[0].Filetypes = []string{".go"}
+	cfg.Watchers[0].Shell.Tasks = []config.Task{
+		{Command: "echo hello"},
+		{Args: []string{"echo", "hello world"}},
+		{Name: "vet", Args: []string{"go", "vet"}, DependsOn: []string{}, ContinueOnError: true},
+		{Name: "build", Command: "go build", DependsOn: []string{"vet"}},
+	}
+	cfg.Watchers[0].Shell.Services = []config.ServiceConfig{{Name: "worker", Command: "go run ./worker", ShutdownTimeout: 1000}}
 
-	return config
+	return cfg
 }
 
 func TestDefaultConfig(t *testing.T) {
@@ -25,3 +34,158 @@ func TestDefaultConfig(t *testing.T) {
 		t.Fatalf("expected at least one watcher in default config")
 	}
 }
+
+func TestWatcherConfig_CSSFiletypes(t *testing.T) {
+	watcher := config.WatcherConfig{RefreshModes: map[string]string{
+		".scss": config.RefreshCSS,
+		".css":  config.RefreshCSS,
+		".html": config.RefreshReload,
+	}}
+
+	got := watcher.CSSFiletypes()
+	if len(got) != 2 || got[0] != ".css" || got[1] != ".scss" {
+		t.Errorf("CSSFiletypes() = %v, expected [.css .scss]", got)
+	}
+}
+
+func TestShellConfig_AllServices(t *testing.T) {
+	tests := []struct {
+		name     string
+		shell    config.ShellConfig
+		expected []config.ServiceConfig
+	}{
+		{
+			name:  "no services",
+			shell: config.ShellConfig{ServiceShutdownTimeout: 5000},
+		},
+		{
+			name:     "single service",
+			shell:    config.ShellConfig{Service: "./app", ServiceShutdownTimeout: 5000},
+			expected: []config.ServiceConfig{{Command: "./app", ShutdownTimeout: 5000}},
+		},
+		{
+			name: "service and named services",
+			shell: config.ShellConfig{
+				Service:                "./app",
+				ServiceShutdownTimeout: 5000,
+				Services: []config.ServiceConfig{
+					{Name: "api", Command: "./api"},
+					{Name: "worker", Command: "./worker", ShutdownTimeout: 1000},
+				},
+			},
+			expected: []config.ServiceConfig{
+				{Command: "./app", ShutdownTimeout: 5000},
+				{Name: "api", Command: "./api", ShutdownTimeout: 5000},
+				{Name: "worker", Command: "./worker", ShutdownTimeout: 1000},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.shell.AllServices(); !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("AllServices() = %+v, expected %+v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestReadConfig_Defaults(t *testing.T) {
+	files := map[string]string{
+		"eavesdrop.json": `{
+	"global_exclude": {"dirs": ["vendor"]},
+	"watchers": [
+		{"name": "go", "filetypes": [".go"], "shell": {"tasks": ["go build"], "readiness": {"tcp": "localhost:8000"}}},
+		{"name": "css", "shell": {"task_timeout": 500}, "run_on_start": false}
+	],
+	"proxy": {"enabled": true}
+}`,
+		"eavesdrop.yaml": `global_exclude:
+  dirs: [vendor]
+watchers:
+  - name: go
+    filetypes: [.go]
+    shell:
+      tasks: [go build]
+      readiness:
+        tcp: localhost:8000
+  - name: css
+    shell:
+      task_timeout: 500
+    run_on_start: false
+proxy:
+  enabled: true
+`,
+		"eavesdrop.toml": `[global_exclude]
+dirs = ["vendor"]
+
+[[watchers]]
+name = "go"
+filetypes = [".go"]
+
+  [watchers.shell]
+  tasks = ["go build"]
+
+  [watchers.shell.readiness]
+  tcp = "localhost:8000"
+
+[[watchers]]
+name = "css"
+run_on_start = false
+
+  [watchers.shell]
+  task_timeout = 500
+
+[proxy]
+enabled = true
+`,
+	}
+
+	expected := config.DefaultConfig()
+	expected.GlobalExclude.Dirs = []string{"vendor"}
+	expected.Proxy.Enabled = true
+
+	goWatcher := config.DefaultWatcherConfig("go")
+	goWatcher.Filetypes = []string{".go"}
+	goWatcher.Shell.Tasks = []config.Task{{Command: "go build"}}
+	goWatcher.Shell.Readiness.TCP = "localhost:8000"
+
+	cssWatcher := config.DefaultWatcherConfig("css")
+	cssWatcher.Shell.TaskTimeout = 500
+	cssWatcher.RunOnStart = false
+
+	expected.Watchers = []config.WatcherConfig{goWatcher, cssWatcher}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := config.GetConfig(path)
+			if err != nil {
+				t.Fatalf("GetConfig() = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("expected\n%+v\n\ngot\n%+v", expected, got)
+			}
+		})
+	}
+}
+
+func TestReadConfig_Examples(t *testing.T) {
+	for _, name := range []string{"eavesdrop.json", "eavesdrop.toml", "eavesdrop.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			_, problems, err := config.ValidateFile(filepath.Join("..", "..", "examples", name))
+			if err != nil {
+				t.Fatalf("ValidateFile() = %v", err)
+			}
+
+			for _, problem := range problems {
+				t.Errorf("unexpected problem: %v", problem)
+			}
+		})
+	}
+}
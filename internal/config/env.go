@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// envPattern matches ${VAR} and ${VAR:-default}, and the escaped forms $${VAR} and $${VAR:-default}.
+var envPattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// ExpandEnv replaces each ${VAR} and ${VAR:-default} in s with the value of VAR from lookup. The
+// default is used when VAR is unset or empty. An unset VAR without a default is left as is, so
+// variables set later, such as those of a task's shell, still reach it; $${VAR} is always left
+// as ${VAR}.
+func ExpandEnv(s string, lookup func(string) (string, bool)) string {
+	return envPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+
+		groups := envPattern.FindStringSubmatch(match)
+		value, ok := lookup(groups[1])
+		switch {
+		case ok && value != "":
+			return value
+		case groups[2] != "":
+			return groups[3]
+		case ok:
+			return ""
+		default:
+			return match
+		}
+	})
+}
+
+// Environ returns the variables loaded from EnvFile in KEY=value form, sorted by key.
+func (c Config) Environ() []string {
+	return environ(c.Env)
+}
+
+// environ returns vars in KEY=value form, sorted by key.
+func environ(vars map[string]string) []string {
+	env := make([]string, 0, len(vars))
+	for _, key := range slices.Sorted(maps.Keys(vars)) {
+		env = append(env, key+"="+vars[key])
+	}
+	return env
+}
+
+// expandEnv loads the config's env files, then expands environment variables in every string
+// setting. Variables set in the process environment take precedence over those in env files.
+func (c *Config) expandEnv() error {
+	lookup := os.LookupEnv
+
+	c.RootDir = ExpandEnv(c.RootDir, lookup)
+	for i, file := range c.EnvFile {
+		c.EnvFile[i] = ExpandEnv(file, lookup)
+	}
+
+	env, err := loadEnvFiles(c.RootDir, c.EnvFile)
+	if err != nil {
+		return err
+	}
+
+	expandStrings(reflect.ValueOf(c).Elem(), func(s string) string {
+		return ExpandEnv(s, func(key string) (string, bool) {
+			if value, ok := env[key]; ok {
+				return value, true
+			}
+			return lookup(key)
+		})
+	})
+	c.Env = env
+
+	return nil
+}
+
+// loadEnvFiles reads the .env files, relative to root, with later files overriding earlier ones.
+// Variables already set in the process environment are omitted.
+func loadEnvFiles(root string, files []string) (map[string]string, error) {
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		paths = append(paths, filepath.Join(root, file))
+	}
+
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	loaded, err := godotenv.Read(paths...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load env_file: %w", err)
+	}
+
+	env := make(map[string]string, len(loaded))
+	for key, value := range loaded {
+		if _, ok := os.LookupEnv(key); !ok {
+			env[key] = value
+		}
+	}
+
+	return env, nil
+}
+
+// expandStrings applies expand to every string, and every string in a slice, map value, or nested
+// struct, reachable from v.
+func expandStrings(v reflect.Value, expand func(string) string) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(expand(v.String()))
+
+	case reflect.Struct:
+		for i := range v.NumField() {
+			expandStrings(v.Field(i), expand)
+		}
+
+	case reflect.Slice:
+		for i := range v.Len() {
+			expandStrings(v.Index(i), expand)
+		}
+
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			return
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			v.SetMapIndex(iter.Key(), reflect.ValueOf(expand(iter.Value().String())).Convert(v.Type().Elem()))
+		}
+	}
+}
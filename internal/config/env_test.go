@@ -0,0 +1,163 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dimmerz92/eavesdrop/v2/internal/config"
+)
+
+func TestExpandEnv(t *testing.T) {
+	env := map[string]string{"PORT": "3000", "EMPTY": ""}
+	lookup := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"no variables", "go build", "go build"},
+		{"set variable", "localhost:${PORT}", "localhost:3000"},
+		{"unset variable untouched", "localhost:${HOST}", "localhost:${HOST}"},
+		{"empty variable", "localhost:${EMPTY}", "localhost:"},
+		{"default unused", "${PORT:-8000}", "3000"},
+		{"default for unset", "${HOST:-localhost}", "localhost"},
+		{"default for empty", "${EMPTY:-fallback}", "fallback"},
+		{"empty default", "${HOST:-}", ""},
+		{"multiple variables", "${HOST:-localhost}:${PORT}", "localhost:3000"},
+		{"bare dollar untouched", "$PORT ^.+\\.go$", "$PORT ^.+\\.go$"},
+		{"escaped variable", "$${PORT} and $${HOST:-localhost}", "${PORT} and ${HOST:-localhost}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := config.ExpandEnv(test.input, lookup); got != test.expected {
+				t.Errorf("ExpandEnv(%q) = %q, expected %q", test.input, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestGetConfig_Env(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Setenv("EAVESDROP_TEST_BIN", "from-process")
+	t.Setenv("EAVESDROP_TEST_ENV_FILE", ".env")
+
+	files := map[string]string{
+		".env":       "EAVESDROP_TEST_PORT=3000\nEAVESDROP_TEST_BIN=from-file\nEAVESDROP_TEST_DIR=web\n",
+		".env.local": "EAVESDROP_TEST_DIR=src\n",
+		"eavesdrop.yaml": `root_dir: ` + dir + `
+env_file:
+  - ${EAVESDROP_TEST_ENV_FILE}
+  - .env.local
+watchers:
+  - name: app
+    dirs:
+      - ${EAVESDROP_TEST_DIR}
+    shell:
+      tasks:
+        - ${EAVESDROP_TEST_BIN} build
+        - go test ${EAVESDROP_PATH}
+        - echo $${EAVESDROP_TEST_PORT}
+      service: ./tmp/app -port ${EAVESDROP_TEST_PORT}
+      readiness:
+        tcp: ${EAVESDROP_TEST_HOST:-localhost}:${EAVESDROP_TEST_PORT}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := config.GetConfig(filepath.Join(dir, "eavesdrop.yaml"))
+	if err != nil {
+		t.Fatalf("GetConfig() = %v", err)
+	}
+
+	watcher := cfg.Watchers[0]
+	checks := []struct {
+		field    string
+		got      any
+		expected any
+	}{
+		{"env_file", cfg.EnvFile, []string{".env", ".env.local"}},
+		{"dirs", watcher.Dirs, []string{"src"}},
+		{"tasks", watcher.Shell.Tasks, []config.Task{
+			{Command: "from-process build"},
+			{Command: "go test ${EAVESDROP_PATH}"},
+			{Command: "echo ${EAVESDROP_TEST_PORT}"},
+		}},
+		{"service", watcher.Shell.Service, "./tmp/app -port 3000"},
+		{"readiness.tcp", watcher.Shell.Readiness.TCP, "localhost:3000"},
+		{"Environ", cfg.Environ(), []string{"EAVESDROP_TEST_DIR=src", "EAVESDROP_TEST_PORT=3000"}},
+	}
+
+	for _, check := range checks {
+		if !reflect.DeepEqual(check.got, check.expected) {
+			t.Errorf("%s = %v, expected %v", check.field, check.got, check.expected)
+		}
+	}
+
+	t.Run("MissingEnvFile", func(t *testing.T) {
+		t.Setenv("EAVESDROP_TEST_ENV_FILE", ".env.missing")
+
+		if _, err := config.GetConfig(filepath.Join(dir, "eavesdrop.yaml")); err == nil {
+			t.Error("expected error for missing env_file")
+		}
+	})
+}
+
+func TestGetConfig_EnvPort(t *testing.T) {
+	t.Setenv("EAVESDROP_TEST_APP_PORT", "3000")
+
+	tests := []struct {
+		file    string
+		content string
+	}{
+		{
+			file:    "eavesdrop.yaml",
+			content: "env_file:\n  - .env\nproxy:\n  app_port: ${EAVESDROP_TEST_APP_PORT}\n  proxy_port: ${EAVESDROP_TEST_PROXY_PORT:-3001}\n",
+		},
+		{
+			file:    "eavesdrop.json",
+			content: `{"env_file": [".env"], "proxy": {"app_port": "${EAVESDROP_TEST_APP_PORT}", "proxy_port": "${EAVESDROP_TEST_PROXY_PORT:-3001}"}}`,
+		},
+		{
+			file:    "eavesdrop.toml",
+			content: "env_file = [\".env\"]\n[proxy]\napp_port = \"${EAVESDROP_TEST_APP_PORT}\"\nproxy_port = \"${EAVESDROP_TEST_PROXY_PORT:-3001}\"\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("EAVESDROP_TEST_PROXY_PORT=\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, test.file)
+			if err := os.WriteFile(path, []byte(test.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			t.Chdir(dir)
+			cfg, err := config.GetConfig(path)
+			if err != nil {
+				t.Fatalf("GetConfig() = %v", err)
+			}
+
+			if got := cfg.Proxy.AppPort.Uint16(); got != 3000 {
+				t.Errorf("app_port = %d, expected 3000", got)
+			}
+			if got := cfg.Proxy.ProxyPort.Uint16(); got != 3001 {
+				t.Errorf("proxy_port = %d, expected 3001", got)
+			}
+		})
+	}
+}
@@ -31,11 +31,27 @@ func ReadJsonConfig(inPath string) (Config, error) {
 		return Config{}, fmt.Errorf("failed to read json config: %w", err)
 	}
 
-	var config Config
+	config := DefaultConfig()
+	config.Watchers = nil
 	err = json.Unmarshal(file, &config)
 	if err != nil {
 		return Config{}, fmt.Errorf("failed to unmarshal json to config: %w", err)
 	}
 
+	var raw struct {
+		Watchers []json.RawMessage `json:"watchers"`
+	}
+	err = json.Unmarshal(file, &raw)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to unmarshal json to config: %w", err)
+	}
+
+	config.Watchers, err = mergeWatchers(raw.Watchers, func(r json.RawMessage, w *WatcherConfig) error {
+		return json.Unmarshal(r, w)
+	})
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to unmarshal json to config: %w", err)
+	}
+
 	return config, nil
 }
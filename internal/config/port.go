@@ -0,0 +1,95 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Port is a TCP port, written in a config file as a number or as a string that may use
+// environment variables, e.g. "${APP_PORT:-8000}". It is kept as written until the config's
+// variables are expanded, and is checked by Validate.
+type Port string
+
+// NewPort returns port as a Port.
+func NewPort(port uint16) Port {
+	return Port(strconv.FormatUint(uint64(port), 10))
+}
+
+// Uint16 returns the port number, or zero if p is not a valid port.
+func (p Port) Uint16() uint16 {
+	port, err := strconv.ParseUint(string(p), 10, 16)
+	if err != nil {
+		return 0
+	}
+	return uint16(port)
+}
+
+// valid reports whether p is a port number.
+func (p Port) valid() bool {
+	_, err := strconv.ParseUint(string(p), 10, 16)
+	return err == nil
+}
+
+func (p Port) MarshalJSON() ([]byte, error) {
+	if p.valid() {
+		return []byte(p), nil
+	}
+	return json.Marshal(string(p))
+}
+
+func (p *Port) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*p = Port(s)
+		return nil
+	}
+
+	var port uint16
+	if err := json.Unmarshal(data, &port); err != nil {
+		return err
+	}
+	*p = NewPort(port)
+
+	return nil
+}
+
+func (p Port) MarshalYAML() (any, error) {
+	if p.valid() {
+		return p.Uint16(), nil
+	}
+	return string(p), nil
+}
+
+func (p *Port) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+	*p = Port(s)
+	return nil
+}
+
+func (p Port) MarshalTOML() ([]byte, error) {
+	if p.valid() {
+		return []byte(p), nil
+	}
+	return []byte(strconv.Quote(string(p))), nil
+}
+
+func (p *Port) UnmarshalTOML(data any) error {
+	switch v := data.(type) {
+	case int64:
+		if v < 0 || v > 65535 {
+			return fmt.Errorf("port %d out of range", v)
+		}
+		*p = NewPort(uint16(v))
+	case string:
+		*p = Port(v)
+	default:
+		return fmt.Errorf("port must be a number or string, got %T", data)
+	}
+	return nil
+}
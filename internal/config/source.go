@@ -0,0 +1,202 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sourceLines maps the field paths in a config file, e.g. watchers[0].shell.tasks[1], to the
+// line each is defined on. Fields that cannot be located are omitted.
+func sourceLines(ext string, data []byte) map[string]int {
+	switch ext {
+	case ".json":
+		return jsonLines(data)
+	case ".yaml", ".yml":
+		return yamlLines(data)
+	case ".toml":
+		return tomlLines(data)
+	default:
+		return nil
+	}
+}
+
+// lookupLine returns the line of field, or of its closest located parent, or 0 if neither
+// could be located.
+func lookupLine(lines map[string]int, field string) int {
+	for field != "" {
+		if line, ok := lines[field]; ok {
+			return line
+		}
+
+		idx := strings.LastIndexAny(field, ".[")
+		if idx == -1 {
+			return 0
+		}
+		field = field[:idx]
+	}
+	return 0
+}
+
+// joinField appends key to the field path parent.
+func joinField(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+func jsonLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	lineAt := func() int {
+		return 1 + bytes.Count(data[:dec.InputOffset()], []byte("\n"))
+	}
+
+	var walk func(field string) error
+	walk = func(field string) error {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		if _, ok := lines[field]; !ok && field != "" {
+			lines[field] = lineAt()
+		}
+
+		switch token {
+		case json.Delim('{'):
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+
+				child := joinField(field, fmt.Sprint(key))
+				lines[child] = lineAt()
+
+				err = walk(child)
+				if err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				err = walk(fmt.Sprintf("%s[%d]", field, i))
+				if err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		}
+
+		return err
+	}
+
+	walk("")
+
+	return lines
+}
+
+func yamlLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return lines
+	}
+
+	var walk func(field string, node *yaml.Node)
+	walk = func(field string, node *yaml.Node) {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			for _, child := range node.Content {
+				walk(field, child)
+			}
+
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				child := joinField(field, node.Content[i].Value)
+				lines[child] = node.Content[i].Line
+				walk(child, node.Content[i+1])
+			}
+
+		case yaml.SequenceNode:
+			for i, item := range node.Content {
+				child := fmt.Sprintf("%s[%d]", field, i)
+				lines[child] = item.Line
+				walk(child, item)
+			}
+		}
+	}
+
+	walk("", &root)
+
+	return lines
+}
+
+var (
+	tomlTable = regexp.MustCompile(`^\[\s*([^\[\]]+?)\s*\]`)
+	tomlArray = regexp.MustCompile(`^\[\[\s*([^\[\]]+?)\s*\]\]`)
+	tomlKey   = regexp.MustCompile(`^("[^"]*"|'[^']*'|[\w.-]+)\s*=`)
+)
+
+// tomlLines locates tables, arrays of tables, and keys line by line. Values spanning several
+// lines, such as multi-line arrays, are located at their key.
+func tomlLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+	counts := make(map[string]int) // entries seen so far in each array of tables
+	table := ""
+
+	// resolve converts a dotted table name into a field path, indexing into arrays of tables.
+	resolve := func(name string) string {
+		field := ""
+		for part := range strings.SplitSeq(name, ".") {
+			field = joinField(field, strings.Trim(strings.TrimSpace(part), `"'`))
+			if n, ok := counts[field]; ok {
+				field = fmt.Sprintf("%s[%d]", field, n-1)
+			}
+		}
+		return field
+	}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+
+		if match := tomlArray.FindStringSubmatch(line); match != nil {
+			parent, last := "", match[1]
+			if idx := strings.LastIndex(match[1], "."); idx != -1 {
+				parent, last = resolve(match[1][:idx]), match[1][idx+1:]
+			}
+
+			array := joinField(parent, strings.Trim(strings.TrimSpace(last), `"'`))
+			table = fmt.Sprintf("%s[%d]", array, counts[array])
+			counts[array]++
+
+			lines[table] = i + 1
+			if _, ok := lines[array]; !ok {
+				lines[array] = i + 1
+			}
+			continue
+		}
+
+		if match := tomlTable.FindStringSubmatch(line); match != nil {
+			table = resolve(match[1])
+			lines[table] = i + 1
+			continue
+		}
+
+		if match := tomlKey.FindStringSubmatch(line); match != nil {
+			lines[joinField(table, strings.Trim(match[1], `"'`))] = i + 1
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,257 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Task is a command run by a watcher. In a config file it is either a string, run by the shell,
+// an array of arguments, executed directly without a shell, or an object with the command under
+// "run" alongside the task's name and dependencies.
+type Task struct {
+	Name            string
+	Command         string   // run by the shell, if Args is nil
+	Args            []string // the program and its arguments, executed directly
+	DependsOn       []string // names of the tasks that must succeed first; nil means the previous task
+	ContinueOnError bool     // treat a failure as success for dependent tasks and services
+}
+
+// IsArgv reports whether the task is executed directly rather than by the shell.
+func (t Task) IsArgv() bool {
+	return t.Args != nil
+}
+
+// String returns the command, or the arguments joined by spaces and quoted where necessary.
+func (t Task) String() string {
+	if !t.IsArgv() {
+		return t.Command
+	}
+
+	args := make([]string, len(t.Args))
+	for i, arg := range t.Args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'\\") {
+			arg = strconv.Quote(arg)
+		}
+		args[i] = arg
+	}
+	return strings.Join(args, " ")
+}
+
+// isObject reports whether the task must be encoded as an object rather than just its command.
+func (t Task) isObject() bool {
+	return t.Name != "" || t.DependsOn != nil || t.ContinueOnError
+}
+
+// run returns the string or []string the task's command is encoded as.
+func (t Task) run() any {
+	if t.IsArgv() {
+		return t.Args
+	}
+	return t.Command
+}
+
+// value returns the task as it is encoded: its command, or an object if it has other settings.
+func (t Task) value() any {
+	if !t.isObject() {
+		return t.run()
+	}
+
+	object := map[string]any{"run": t.run()}
+	if t.Name != "" {
+		object["name"] = t.Name
+	}
+	if t.DependsOn != nil {
+		object["depends_on"] = t.DependsOn
+	}
+	if t.ContinueOnError {
+		object["continue_on_error"] = true
+	}
+	return object
+}
+
+// set decodes the task from a string, an array of strings, or an object.
+func (t *Task) set(v any) error {
+	switch v := v.(type) {
+	case string:
+		*t = Task{Command: v}
+
+	case []any:
+		args, err := toStrings("task argument", v)
+		if err != nil {
+			return err
+		}
+		*t = Task{Args: args}
+
+	case map[string]any:
+		return t.setObject(v)
+
+	default:
+		return fmt.Errorf("task must be a string, an array of strings, or an object, not %T", v)
+	}
+
+	return nil
+}
+
+func (t *Task) setObject(object map[string]any) error {
+	*t = Task{}
+
+	for _, key := range slices.Sorted(maps.Keys(object)) {
+		value := object[key]
+
+		switch key {
+		case "name":
+			name, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("task name must be a string, not %T", value)
+			}
+			t.Name = name
+
+		case "run":
+			var run Task
+			if _, ok := value.(map[string]any); ok {
+				return fmt.Errorf("task run must be a string or an array of strings")
+			}
+			if err := run.set(value); err != nil {
+				return err
+			}
+			t.Command, t.Args = run.Command, run.Args
+
+		case "depends_on":
+			values, ok := value.([]any)
+			if !ok {
+				return fmt.Errorf("task depends_on must be an array of task names, not %T", value)
+			}
+			dependsOn, err := toStrings("task depends_on entry", values)
+			if err != nil {
+				return err
+			}
+			t.DependsOn = dependsOn
+
+		case "continue_on_error":
+			continueOnError, ok := value.(bool)
+			if !ok {
+				return fmt.Errorf("task continue_on_error must be a boolean, not %T", value)
+			}
+			t.ContinueOnError = continueOnError
+
+		default:
+			return fmt.Errorf("unknown task field %q", key)
+		}
+	}
+
+	return nil
+}
+
+// toStrings converts values decoded from an array to strings.
+func toStrings(what string, values []any) ([]string, error) {
+	strs := make([]string, len(values))
+	for i, value := range values {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s %d must be a string, not %T", what, i, value)
+		}
+		strs[i] = s
+	}
+	return strs, nil
+}
+
+func (t Task) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.value())
+}
+
+func (t *Task) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return t.set(v)
+}
+
+func (t Task) MarshalYAML() (any, error) {
+	return t.value(), nil
+}
+
+func (t *Task) UnmarshalYAML(node *yaml.Node) error {
+	var v any
+	if err := node.Decode(&v); err != nil {
+		return err
+	}
+	return t.set(v)
+}
+
+// MarshalTOML encodes the task's command as JSON, which is a valid TOML string or array of
+// strings, or the task as an inline table if it has other settings.
+func (t Task) MarshalTOML() ([]byte, error) {
+	if !t.isObject() {
+		return json.Marshal(t.run())
+	}
+
+	run, err := json.Marshal(t.run())
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []string{}
+	if t.Name != "" {
+		name, err := json.Marshal(t.Name)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, "name = "+string(name))
+	}
+	fields = append(fields, "run = "+string(run))
+	if t.DependsOn != nil {
+		dependsOn, err := json.Marshal(t.DependsOn)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, "depends_on = "+string(dependsOn))
+	}
+	if t.ContinueOnError {
+		fields = append(fields, "continue_on_error = true")
+	}
+
+	return []byte("{ " + strings.Join(fields, ", ") + " }"), nil
+}
+
+func (t *Task) UnmarshalTOML(v any) error {
+	return t.set(v)
+}
+
+// Dependencies returns, for each task, the indexes of the tasks it depends on. A task without
+// depends_on depends on the one before it, so tasks run in order unless they say otherwise; an
+// empty depends_on starts the task immediately. Unknown task names are ignored; Validate reports
+// them.
+func Dependencies(tasks []Task) [][]int {
+	deps := make([][]int, len(tasks))
+
+	index := make(map[string]int, len(tasks))
+	for i, task := range tasks {
+		if _, ok := index[task.Name]; task.Name != "" && !ok {
+			index[task.Name] = i
+		}
+	}
+
+	for i, task := range tasks {
+		if task.DependsOn == nil {
+			if i > 0 {
+				deps[i] = []int{i - 1}
+			}
+			continue
+		}
+
+		for _, name := range task.DependsOn {
+			if dep, ok := index[name]; ok {
+				deps[i] = append(deps[i], dep)
+			}
+		}
+	}
+
+	return deps
+}
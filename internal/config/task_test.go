@@ -0,0 +1,137 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dimmerz92/eavesdrop/v2/internal/config"
+)
+
+func TestTask_Decode(t *testing.T) {
+	expected := []config.Task{
+		{Command: "templ generate"},
+		{Name: "css", Args: []string{"tailwindcss", "-o", "static/app.css"}},
+		{Name: "build", Command: "go build", DependsOn: []string{"css"}, ContinueOnError: true},
+	}
+
+	files := map[string]string{
+		"eavesdrop.json": `{"watchers": [{"name": "app", "shell": {"tasks": [
+			"templ generate",
+			{"name": "css", "run": ["tailwindcss", "-o", "static/app.css"]},
+			{"name": "build", "run": "go build", "depends_on": ["css"], "continue_on_error": true}
+		]}}]}`,
+		"eavesdrop.yaml": `watchers:
+  - name: app
+    shell:
+      tasks:
+        - templ generate
+        - name: css
+          run: [tailwindcss, -o, static/app.css]
+        - name: build
+          run: go build
+          depends_on: [css]
+          continue_on_error: true
+`,
+		"eavesdrop.toml": `[[watchers]]
+name = "app"
+[watchers.shell]
+tasks = [
+  "templ generate",
+  { name = "css", run = ["tailwindcss", "-o", "static/app.css"] },
+  { name = "build", run = "go build", depends_on = ["css"], continue_on_error = true },
+]
+`,
+	}
+
+	dir := t.TempDir()
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := config.GetConfig(path)
+			if err != nil {
+				t.Fatalf("GetConfig() = %v", err)
+			}
+
+			if got := cfg.Watchers[0].Shell.Tasks; !reflect.DeepEqual(got, expected) {
+				t.Errorf("expected\n%+v\n\ngot\n%+v", expected, got)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		tests := map[string]string{
+			"number":          `[1]`,
+			"number argument": `[["go", 1]]`,
+			"unknown field":   `[{"run": "go build", "retry": true}]`,
+			"nested run":      `[{"run": {"run": "go build"}}]`,
+			"bad depends_on":  `[{"run": "go build", "depends_on": "vet"}]`,
+		}
+
+		for name, tasks := range tests {
+			t.Run(name, func(t *testing.T) {
+				path := filepath.Join(dir, "invalid.json")
+				content := `{"watchers": [{"name": "app", "shell": {"tasks": ` + tasks + `}}]}`
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := config.GetConfig(path); err == nil {
+					t.Error("expected error")
+				}
+			})
+		}
+	})
+}
+
+func TestDependencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		tasks    []config.Task
+		expected [][]int
+	}{
+		{
+			name:     "sequential without depends_on",
+			tasks:    []config.Task{{Command: "a"}, {Command: "b"}, {Command: "c"}},
+			expected: [][]int{nil, {0}, {1}},
+		},
+		{
+			name: "graph with depends_on",
+			tasks: []config.Task{
+				{Name: "templ", Command: "templ generate"},
+				{Name: "css", Command: "tailwindcss", DependsOn: []string{}},
+				{Name: "build", Command: "go build", DependsOn: []string{"templ", "css"}},
+				{Command: "echo done"},
+			},
+			expected: [][]int{nil, nil, {0, 1}, {2}},
+		},
+		{
+			name: "tasks without depends_on stay in order",
+			tasks: []config.Task{
+				{Name: "fmt", Command: "gofmt -l ."},
+				{Name: "vet", Command: "go vet ./..."},
+				{Name: "css", Command: "tailwindcss", DependsOn: []string{}},
+				{Name: "build", Command: "go build", DependsOn: []string{"vet"}},
+			},
+			expected: [][]int{nil, {0}, nil, {1}},
+		},
+		{
+			name:     "unknown names ignored",
+			tasks:    []config.Task{{Name: "a", Command: "a", DependsOn: []string{"missing"}}},
+			expected: [][]int{nil},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := config.Dependencies(test.tasks); !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("Dependencies() = %v, expected %v", got, test.expected)
+			}
+		})
+	}
+}
@@ -34,11 +34,27 @@ func ReadTomlConfig(inPath string) (Config, error) {
 		return Config{}, fmt.Errorf("toml config is empty")
 	}
 
-	config := Config{}
+	config := DefaultConfig()
+	config.Watchers = nil
 	err = toml.Unmarshal(file, &config)
 	if err != nil {
 		return Config{}, fmt.Errorf("failed to unmarshal toml to config: %w", err)
 	}
 
+	var raw struct {
+		Watchers []toml.Primitive `toml:"watchers"`
+	}
+	meta, err := toml.Decode(string(file), &raw)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to unmarshal toml to config: %w", err)
+	}
+
+	config.Watchers, err = mergeWatchers(raw.Watchers, func(r toml.Primitive, w *WatcherConfig) error {
+		return meta.PrimitiveDecode(r, w)
+	})
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to unmarshal toml to config: %w", err)
+	}
+
 	return config, nil
 }
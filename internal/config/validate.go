@@ -0,0 +1,402 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+var (
+	validOps             = []string{"CHMOD", "CREATE", "REMOVE", "RENAME", "WRITE"}
+	validBackends        = []string{"", BackendFsnotify, BackendPoll}
+	validRestartPolicies = []string{"", "never", "on-failure", "always"}
+	validRefreshModes    = []string{RefreshReload, RefreshCSS}
+	validRunModes        = []string{"", RunModeQueue, RunModeLatest}
+)
+
+// Problem is an invalid setting found by Validate.
+type Problem struct {
+	Field   string // path to the setting, e.g. watchers[0].exclude.regex[1]
+	Line    int    // line of the setting in the config file, or 0 if unknown
+	Message string
+}
+
+func (p Problem) Error() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", p.Line, p.Field, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.Field, p.Message)
+}
+
+// problems collects Problems as a config is validated.
+type problems []Problem
+
+func (p *problems) add(field, format string, args ...any) {
+	*p = append(*p, Problem{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks config for settings that would fail or silently misbehave at runtime, returning
+// every problem found. Directories are resolved relative to the root directory.
+func Validate(config Config) []Problem {
+	var p problems
+
+	if info, err := os.Stat(config.RootDir); err != nil {
+		p.add("root_dir", "directory %q does not exist", config.RootDir)
+	} else if !info.IsDir() {
+		p.add("root_dir", "%q is not a directory", config.RootDir)
+	}
+
+	if !slices.Contains(validBackends, config.Backend) {
+		p.add("backend", "unknown backend %q, expected %q or %q", config.Backend, BackendFsnotify, BackendPoll)
+	}
+
+	if config.Backend == BackendPoll && config.PollInterval == 0 {
+		p.add("poll_interval", "must be greater than zero when polling")
+	}
+
+	p.validateExcluder("global_exclude", config.GlobalExclude)
+
+	if len(config.Watchers) == 0 {
+		p.add("watchers", "at least one watcher is required")
+	}
+
+	names := make(map[string]int, len(config.Watchers))
+	for i, watcher := range config.Watchers {
+		field := fmt.Sprintf("watchers[%d]", i)
+
+		name := strings.TrimSpace(watcher.Name)
+		if name == "" {
+			p.add(field+".name", "must not be empty")
+		} else if first, ok := names[name]; ok {
+			p.add(field+".name", "duplicate watcher name %q, also used by watchers[%d]", name, first)
+		} else {
+			names[name] = i
+		}
+
+		p.validateWatcher(field, config, watcher)
+	}
+
+	p.validateTriggers(config.Watchers, names)
+
+	if config.Proxy.Enabled {
+		p.validatePort("proxy.app_port", config.Proxy.AppPort)
+		p.validatePort("proxy.proxy_port", config.Proxy.ProxyPort)
+		if port := config.Proxy.AppPort.Uint16(); port != 0 && port == config.Proxy.ProxyPort.Uint16() {
+			p.add("proxy.proxy_port", "conflicts with proxy.app_port %d", port)
+		}
+	}
+
+	return p
+}
+
+func (p *problems) validateWatcher(field string, config Config, watcher WatcherConfig) {
+	for i, filetype := range watcher.Filetypes {
+		if !strings.HasPrefix(filetype, ".") {
+			p.add(fmt.Sprintf("%s.filetypes[%d]", field, i), "%q must start with a dot, e.g. %q", filetype, "."+filetype)
+		}
+	}
+
+	for i, dir := range watcher.Dirs {
+		info, err := os.Stat(filepath.Join(config.RootDir, dir))
+		if err != nil {
+			p.add(fmt.Sprintf("%s.dirs[%d]", field, i), "directory %q does not exist", dir)
+		} else if !info.IsDir() {
+			p.add(fmt.Sprintf("%s.dirs[%d]", field, i), "%q is not a directory", dir)
+		}
+	}
+
+	p.validateGlobs(field+".globs", watcher.Globs)
+	p.validateExcluder(field+".exclude", watcher.Exclude)
+
+	if !slices.Contains(validRunModes, watcher.RunMode) {
+		p.add(field+".run_mode", "unknown run mode %q, expected %q or %q", watcher.RunMode, RunModeQueue, RunModeLatest)
+	}
+
+	if watcher.Lock != "" && strings.TrimSpace(watcher.Lock) == "" {
+		p.add(field+".lock", "must not be blank, expected %q, %q, or a group name", LockGlobal, LockWatcher)
+	}
+
+	shell := watcher.Shell
+	p.validateTasks(field+".shell.tasks", shell.Tasks)
+
+	serviceNames := make(map[string]int, len(shell.Services))
+	for i, service := range shell.Services {
+		serviceField := fmt.Sprintf("%s.shell.services[%d]", field, i)
+
+		name := strings.TrimSpace(service.Name)
+		if name == "" {
+			p.add(serviceField+".name", "must not be empty")
+		} else if first, ok := serviceNames[name]; ok {
+			p.add(serviceField+".name", "duplicate service name %q, also used by services[%d]", name, first)
+		} else {
+			serviceNames[name] = i
+		}
+
+		if strings.TrimSpace(service.Command) == "" {
+			p.add(serviceField+".command", "must not be empty")
+		}
+	}
+
+	if shell.Shell.Path != "" {
+		if _, err := exec.LookPath(shell.Shell.Path); err != nil {
+			p.add(field+".shell.shell.path", "shell %q not found", shell.Shell.Path)
+		}
+	} else if shell.Shell.Flag != "" {
+		p.add(field+".shell.shell.flag", "requires shell.path to be set")
+	}
+
+	if shell.Cwd != "" {
+		cwd := shell.Cwd
+		if !filepath.IsAbs(cwd) {
+			cwd = filepath.Join(config.RootDir, cwd)
+		}
+		if info, err := os.Stat(cwd); err != nil {
+			p.add(field+".shell.cwd", "directory %q does not exist", shell.Cwd)
+		} else if !info.IsDir() {
+			p.add(field+".shell.cwd", "%q is not a directory", shell.Cwd)
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(shell.Env)) {
+		if key == "" || strings.ContainsAny(key, "= ") {
+			p.add(field+".shell.env", "invalid variable name %q", key)
+		}
+	}
+
+	if shell.TaskTimeout == 0 {
+		p.add(field+".shell.task_timeout", "must be greater than zero")
+	}
+
+	if shell.ServiceShutdownTimeout == 0 {
+		p.add(field+".shell.service_shutdown_timeout", "must be greater than zero")
+	}
+
+	readiness := shell.Readiness
+	if readiness.TCP != "" || readiness.HTTP != "" || readiness.Log != "" {
+		if readiness.Timeout == 0 {
+			p.add(field+".shell.readiness.timeout", "must be greater than zero when a readiness probe is set")
+		}
+		if readiness.Interval == 0 && (readiness.TCP != "" || readiness.HTTP != "") {
+			p.add(field+".shell.readiness.interval", "must be greater than zero when a readiness probe is set")
+		}
+	}
+
+	if readiness.Log != "" {
+		if _, err := regexp.Compile(readiness.Log); err != nil {
+			p.add(field+".shell.readiness.log", "invalid regex: %v", err)
+		}
+	}
+
+	if !slices.Contains(validRestartPolicies, strings.ToLower(shell.Restart.Policy)) {
+		p.add(field+".shell.restart.policy", "unknown policy %q, expected never, on-failure, or always", shell.Restart.Policy)
+	} else if shell.Restart.Backoff == 0 && !strings.EqualFold(shell.Restart.Policy, "never") {
+		p.add(field+".shell.restart.backoff", "must be greater than zero when services are restarted")
+	}
+
+	for _, ext := range slices.Sorted(maps.Keys(watcher.RefreshModes)) {
+		modeField := fmt.Sprintf("%s.refresh_modes.%s", field, ext)
+		if !strings.HasPrefix(ext, ".") {
+			p.add(modeField, "%q must start with a dot, e.g. %q", ext, "."+ext)
+		}
+		if mode := watcher.RefreshModes[ext]; !slices.Contains(validRefreshModes, mode) {
+			p.add(modeField, "unknown refresh mode %q, expected %q or %q", mode, RefreshReload, RefreshCSS)
+		}
+	}
+}
+
+func (p *problems) validateTasks(field string, tasks []Task) {
+	names := make(map[string]int, len(tasks))
+	for i, task := range tasks {
+		taskField := fmt.Sprintf("%s[%d]", field, i)
+
+		if task.IsArgv() && (len(task.Args) == 0 || strings.TrimSpace(task.Args[0]) == "") {
+			p.add(taskField, "argument list must start with a program")
+		} else if !task.IsArgv() && strings.TrimSpace(task.Command) == "" {
+			p.add(taskField, "must not be empty")
+		}
+
+		if task.Name == "" {
+			continue
+		}
+		if first, ok := names[task.Name]; ok {
+			p.add(taskField+".name", "duplicate task name %q, also used by tasks[%d]", task.Name, first)
+		} else {
+			names[task.Name] = i
+		}
+	}
+
+	for i, task := range tasks {
+		for j, name := range task.DependsOn {
+			depField := fmt.Sprintf("%s[%d].depends_on[%d]", field, i, j)
+			if dep, ok := names[name]; !ok {
+				p.add(depField, "unknown task %q", name)
+			} else if dep == i {
+				p.add(depField, "task cannot depend on itself")
+			}
+		}
+	}
+
+	if cycle := findCycle(Dependencies(tasks)); len(cycle) > 1 {
+		path := cyclePath(cycle, func(i int) string { return tasks[i].Name })
+		p.add(fmt.Sprintf("%s[%d].depends_on", field, cycle[0]), "dependency cycle: %s", path)
+	}
+}
+
+// validateTriggers checks that on_success and on_failure name other watchers, and that watchers
+// do not trigger each other in a loop. names maps each watcher name to its index.
+func (p *problems) validateTriggers(watchers []WatcherConfig, names map[string]int) {
+	deps := make([][]int, len(watchers))
+
+	for i, watcher := range watchers {
+		for _, trigger := range []struct {
+			field string
+			names []string
+		}{
+			{"on_success", watcher.OnSuccess},
+			{"on_failure", watcher.OnFailure},
+		} {
+			for j, name := range trigger.names {
+				field := fmt.Sprintf("watchers[%d].%s[%d]", i, trigger.field, j)
+				if target, ok := names[name]; !ok {
+					p.add(field, "unknown watcher %q", name)
+				} else if target == i {
+					p.add(field, "watcher cannot trigger itself")
+				} else {
+					deps[i] = append(deps[i], target)
+				}
+			}
+		}
+	}
+
+	if cycle := findCycle(deps); len(cycle) > 1 {
+		path := cyclePath(cycle, func(i int) string { return watchers[i].Name })
+
+		field := "on_success"
+		if !slices.Contains(watchers[cycle[0]].OnSuccess, watchers[cycle[1]].Name) {
+			field = "on_failure"
+		}
+		p.add(fmt.Sprintf("watchers[%d].%s", cycle[0], field), "trigger cycle: %s", path)
+	}
+}
+
+// cyclePath returns the names of the nodes in cycle, joined by arrows and ending where it began,
+// e.g. "a -> b -> a".
+func cyclePath(cycle []int, name func(node int) string) string {
+	path := make([]string, 0, len(cycle)+1)
+	for _, node := range cycle {
+		path = append(path, name(node))
+	}
+	path = append(path, name(cycle[0]))
+	return strings.Join(path, " -> ")
+}
+
+// findCycle returns the nodes of a cycle in the graph, where deps[i] lists the nodes that node i
+// points to, or nil if there is none. Self loops are ignored.
+func findCycle(deps [][]int) []int {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make([]int, len(deps))
+	var stack []int
+
+	var visit func(node int) []int
+	visit = func(node int) []int {
+		state[node] = visiting
+		stack = append(stack, node)
+
+		for _, next := range deps[node] {
+			switch {
+			case next == node:
+			case state[next] == visiting:
+				return slices.Clone(stack[slices.Index(stack, next):])
+			case state[next] == unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[node] = visited
+		return nil
+	}
+
+	for node := range deps {
+		if state[node] == unvisited {
+			if cycle := visit(node); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *problems) validateExcluder(field string, excluder ExcluderConfig) {
+	for i, op := range excluder.Ops {
+		if !slices.Contains(validOps, strings.ToUpper(op)) {
+			p.add(fmt.Sprintf("%s.ops[%d]", field, i), "unknown op %q, expected one of %s", op, strings.Join(validOps, ", "))
+		}
+	}
+
+	for i, pattern := range excluder.Regex {
+		if _, err := regexp.Compile(pattern); err != nil {
+			p.add(fmt.Sprintf("%s.regex[%d]", field, i), "invalid regex: %v", err)
+		}
+	}
+
+	p.validateGlobs(field+".globs", excluder.Globs)
+}
+
+func (p *problems) validateGlobs(field string, globs []string) {
+	for i, glob := range globs {
+		if !doublestar.ValidatePattern(glob) {
+			p.add(fmt.Sprintf("%s[%d]", field, i), "invalid glob %q", glob)
+		}
+	}
+}
+
+func (p *problems) validatePort(field string, port Port) {
+	switch {
+	case !port.valid():
+		p.add(field, "must be a port number, got %q", string(port))
+	case port.Uint16() == 0:
+		p.add(field, "must not be zero")
+	}
+}
+
+// ValidateFile reads and validates the config file at path, locating each problem's line in the
+// file where possible. The returned error is non-nil only if the file cannot be read or parsed.
+func ValidateFile(path string) (Config, []Problem, error) {
+	config, err := GetConfig(path)
+	if err != nil {
+		return config, nil, err
+	}
+
+	problems := Validate(config)
+	if len(problems) == 0 {
+		return config, nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, problems, nil
+	}
+
+	lines := sourceLines(filepath.Ext(path), data)
+	for i := range problems {
+		problems[i].Line = lookupLine(lines, problems[i].Field)
+	}
+
+	return config, problems, nil
+}
@@ -0,0 +1,364 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/dimmerz92/eavesdrop/v2/internal/config"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(c *config.Config)
+		expected []string
+	}{
+		{
+			name:   "default config is valid",
+			modify: func(c *config.Config) {},
+		},
+		{
+			name:     "missing root dir",
+			modify:   func(c *config.Config) { c.RootDir = "does-not-exist" },
+			expected: []string{"root_dir"},
+		},
+		{
+			name:     "unknown backend",
+			modify:   func(c *config.Config) { c.Backend = "inotify" },
+			expected: []string{"backend"},
+		},
+		{
+			name:     "zero poll interval",
+			modify:   func(c *config.Config) { c.Backend, c.PollInterval = config.BackendPoll, 0 },
+			expected: []string{"poll_interval"},
+		},
+		{
+			name:     "unknown op",
+			modify:   func(c *config.Config) { c.GlobalExclude.Ops = []string{"chmod", "MODIFY"} },
+			expected: []string{"global_exclude.ops[1]"},
+		},
+		{
+			name:     "bad regex",
+			modify:   func(c *config.Config) { c.Watchers[0].Exclude.Regex = []string{"(unclosed"} },
+			expected: []string{"watchers[0].exclude.regex[0]"},
+		},
+		{
+			name:     "bad glob",
+			modify:   func(c *config.Config) { c.Watchers[0].Globs = []string{"[unclosed"} },
+			expected: []string{"watchers[0].globs[0]"},
+		},
+		{
+			name:     "no watchers",
+			modify:   func(c *config.Config) { c.Watchers = nil },
+			expected: []string{"watchers"},
+		},
+		{
+			name: "duplicate and empty names",
+			modify: func(c *config.Config) {
+				c.Watchers = append(c.Watchers, c.Watchers[0], c.Watchers[0])
+				c.Watchers[2].Name = " "
+			},
+			expected: []string{"watchers[1].name", "watchers[2].name"},
+		},
+		{
+			name:     "filetype without dot",
+			modify:   func(c *config.Config) { c.Watchers[0].Filetypes = []string{".go", "templ"} },
+			expected: []string{"watchers[0].filetypes[1]"},
+		},
+		{
+			name:     "missing dir",
+			modify:   func(c *config.Config) { c.Watchers[0].Dirs = []string{"does-not-exist"} },
+			expected: []string{"watchers[0].dirs[0]"},
+		},
+		{
+			name:     "unknown run mode",
+			modify:   func(c *config.Config) { c.Watchers[0].RunMode = "restart" },
+			expected: []string{"watchers[0].run_mode"},
+		},
+		{
+			name: "triggers",
+			modify: func(c *config.Config) {
+				c.Watchers = append(c.Watchers, c.Watchers[0])
+				c.Watchers[1].Name = "server"
+				c.Watchers[0].OnSuccess = []string{"server"}
+				c.Watchers[0].OnFailure = []string{"server"}
+			},
+		},
+		{
+			name: "unknown trigger",
+			modify: func(c *config.Config) {
+				c.Watchers[0].OnSuccess = []string{"missing"}
+				c.Watchers[0].OnFailure = []string{c.Watchers[0].Name}
+			},
+			expected: []string{"watchers[0].on_success[0]", "watchers[0].on_failure[0]"},
+		},
+		{
+			name: "trigger cycle",
+			modify: func(c *config.Config) {
+				c.Watchers = append(c.Watchers, c.Watchers[0], c.Watchers[0])
+				c.Watchers[1].Name, c.Watchers[2].Name = "codegen", "server"
+				c.Watchers[0].OnSuccess = []string{"codegen"}
+				c.Watchers[1].OnSuccess = []string{"server"}
+				c.Watchers[2].OnFailure = []string{"codegen"}
+			},
+			expected: []string{"watchers[1].on_success"},
+		},
+		{
+			name:     "blank lock",
+			modify:   func(c *config.Config) { c.Watchers[0].Lock = " " },
+			expected: []string{"watchers[0].lock"},
+		},
+		{
+			name: "lock group",
+			modify: func(c *config.Config) {
+				c.Watchers = append(c.Watchers, c.Watchers[0])
+				c.Watchers[1].Name = "other"
+				c.Watchers[0].Lock, c.Watchers[1].Lock = "frontend", "frontend"
+			},
+		},
+		{
+			name: "bad task graph",
+			modify: func(c *config.Config) {
+				c.Watchers[0].Shell.Tasks = []config.Task{
+					{Name: "a", Command: "a", DependsOn: []string{"c"}},
+					{Name: "b", Command: "b", DependsOn: []string{"a", "missing"}},
+					{Name: "c", Command: "c", DependsOn: []string{"b"}},
+					{Name: "a", Command: "d", DependsOn: []string{}},
+					{Name: "e", Command: "e", DependsOn: []string{"e"}},
+				}
+			},
+			expected: []string{
+				"watchers[0].shell.tasks[3].name",
+				"watchers[0].shell.tasks[1].depends_on[1]",
+				"watchers[0].shell.tasks[4].depends_on[0]",
+				"watchers[0].shell.tasks[0].depends_on",
+			},
+		},
+		{
+			name: "bad services",
+			modify: func(c *config.Config) {
+				c.Watchers[0].Shell.Services = []config.ServiceConfig{
+					{Name: "api", Command: "./api"},
+					{Name: "api", Command: "./worker"},
+					{Name: "", Command: " "},
+				}
+			},
+			expected: []string{
+				"watchers[0].shell.services[1].name",
+				"watchers[0].shell.services[2].name",
+				"watchers[0].shell.services[2].command",
+			},
+		},
+		{
+			name: "empty argv task",
+			modify: func(c *config.Config) {
+				c.Watchers[0].Shell.Tasks = []config.Task{{Command: "go build"}, {Args: []string{}}, {Args: []string{"go", "vet"}}}
+			},
+			expected: []string{"watchers[0].shell.tasks[1]"},
+		},
+		{
+			name: "bad shell settings",
+			modify: func(c *config.Config) {
+				c.Watchers[0].Shell.Cwd = "does-not-exist"
+				c.Watchers[0].Shell.Env = map[string]string{"PORT": "3000", "BAD NAME": "x"}
+				c.Watchers = append(c.Watchers, config.DefaultWatcherConfig("other"), config.DefaultWatcherConfig("another"))
+				c.Watchers[1].Shell.Shell = config.InterpreterConfig{Path: "does-not-exist-sh"}
+				c.Watchers[2].Shell.Shell = config.InterpreterConfig{Flag: "-c"}
+			},
+			expected: []string{
+				"watchers[0].shell.cwd",
+				"watchers[0].shell.env",
+				"watchers[1].shell.shell.path",
+				"watchers[2].shell.shell.flag",
+			},
+		},
+		{
+			name: "zero timeouts",
+			modify: func(c *config.Config) {
+				c.Watchers[0].Shell.TaskTimeout = 0
+				c.Watchers[0].Shell.ServiceShutdownTimeout = 0
+				c.Watchers[0].Shell.Readiness = config.ReadinessConfig{TCP: "localhost:8000"}
+			},
+			expected: []string{
+				"watchers[0].shell.task_timeout",
+				"watchers[0].shell.service_shutdown_timeout",
+				"watchers[0].shell.readiness.timeout",
+				"watchers[0].shell.readiness.interval",
+			},
+		},
+		{
+			name:     "bad readiness log pattern",
+			modify:   func(c *config.Config) { c.Watchers[0].Shell.Readiness.Log = "(unclosed" },
+			expected: []string{"watchers[0].shell.readiness.log"},
+		},
+		{
+			name:     "unknown restart policy",
+			modify:   func(c *config.Config) { c.Watchers[0].Shell.Restart.Policy = "sometimes" },
+			expected: []string{"watchers[0].shell.restart.policy"},
+		},
+		{
+			name: "zero restart backoff",
+			modify: func(c *config.Config) {
+				c.Watchers[0].Shell.Restart.Policy, c.Watchers[0].Shell.Restart.Backoff = "always", 0
+			},
+			expected: []string{"watchers[0].shell.restart.backoff"},
+		},
+		{
+			name:     "zero restart backoff ignored when never restarted",
+			modify:   func(c *config.Config) { c.Watchers[0].Shell.Restart.Backoff = 0 },
+			expected: nil,
+		},
+		{
+			name:     "unknown refresh mode",
+			modify:   func(c *config.Config) { c.Watchers[0].RefreshModes = map[string]string{".css": "swap", "scss": "css"} },
+			expected: []string{"watchers[0].refresh_modes..css", "watchers[0].refresh_modes.scss"},
+		},
+		{
+			name:     "port conflict",
+			modify:   func(c *config.Config) { c.Proxy.Enabled, c.Proxy.ProxyPort = true, c.Proxy.AppPort },
+			expected: []string{"proxy.proxy_port"},
+		},
+		{
+			name:     "port not a number",
+			modify:   func(c *config.Config) { c.Proxy.Enabled, c.Proxy.AppPort = true, "${APP_PORT}" },
+			expected: []string{"proxy.app_port"},
+		},
+		{
+			name:     "port conflict ignored when proxy disabled",
+			modify:   func(c *config.Config) { c.Proxy.ProxyPort = c.Proxy.AppPort },
+			expected: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := config.DefaultConfig()
+			c.RootDir = t.TempDir()
+			test.modify(&c)
+
+			var fields []string
+			for _, problem := range config.Validate(c) {
+				fields = append(fields, problem.Field)
+			}
+
+			if !slices.Equal(fields, test.expected) {
+				t.Errorf("problem fields = %v, expected %v", fields, test.expected)
+			}
+		})
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	files := map[string]string{
+		"eavesdrop.json": `{
+	"root_dir": ".",
+	"watchers": [
+		{
+			"name": "a",
+			"shell": {"task_timeout": 10, "service_shutdown_timeout": 10}
+		},
+		{
+			"name": "b",
+			"exclude": {
+				"regex": [
+					"ok",
+					"(unclosed"
+				]
+			},
+			"shell": {"task_timeout": 0, "service_shutdown_timeout": 10}
+		}
+	]
+}`,
+		"eavesdrop.yaml": `root_dir: .
+watchers:
+  - name: a
+    shell:
+      task_timeout: 10
+      service_shutdown_timeout: 10
+  - name: b
+    exclude:
+      regex:
+        - ok
+        - (unclosed
+    shell: {task_timeout: 0, service_shutdown_timeout: 10}
+`,
+		"eavesdrop.toml": `root_dir = "."
+
+[[watchers]]
+name = "a"
+
+  [watchers.shell]
+  task_timeout = 10
+  service_shutdown_timeout = 10
+
+[[watchers]]
+name = "b"
+
+  [watchers.exclude]
+  regex = [
+    "ok",
+    "(unclosed"
+  ]
+
+  [watchers.shell]
+  task_timeout = 0
+  service_shutdown_timeout = 10
+`,
+	}
+
+	expected := map[string][]config.Problem{
+		"eavesdrop.json": {
+			{Field: "watchers[1].exclude.regex[1]", Line: 13},
+			{Field: "watchers[1].shell.task_timeout", Line: 16},
+		},
+		"eavesdrop.yaml": {
+			{Field: "watchers[1].exclude.regex[1]", Line: 11},
+			{Field: "watchers[1].shell.task_timeout", Line: 12},
+		},
+		"eavesdrop.toml": {
+			{Field: "watchers[1].exclude.regex[1]", Line: 14}, // array elements are located at their key
+			{Field: "watchers[1].shell.task_timeout", Line: 20},
+		},
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			t.Chdir(dir)
+
+			_, problems, err := config.ValidateFile(path)
+			if err != nil {
+				t.Fatalf("ValidateFile() = %v", err)
+			}
+
+			if len(problems) != len(expected[name]) {
+				t.Fatalf("got problems %v, expected %v", problems, expected[name])
+			}
+
+			for i, problem := range problems {
+				if problem.Field != expected[name][i].Field || problem.Line != expected[name][i].Line {
+					t.Errorf("problem %d = %s:%d, expected %s:%d",
+						i, problem.Field, problem.Line, expected[name][i].Field, expected[name][i].Line)
+				}
+			}
+		})
+	}
+
+	t.Run("unparseable file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "eavesdrop.json")
+		if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := config.ValidateFile(path); err == nil {
+			t.Error("ValidateFile() = nil, expected error")
+		}
+	})
+}
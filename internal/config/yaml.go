@@ -34,11 +34,27 @@ func ReadYamlConfig(inPath string) (Config, error) {
 		return Config{}, fmt.Errorf("yaml config is empty")
 	}
 
-	config := Config{}
+	config := DefaultConfig()
+	config.Watchers = nil
 	err = yaml.Unmarshal(file, &config)
 	if err != nil {
 		return Config{}, fmt.Errorf("failed to unmarshal yaml to config: %w", err)
 	}
 
+	var raw struct {
+		Watchers []yaml.Node `yaml:"watchers"`
+	}
+	err = yaml.Unmarshal(file, &raw)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to unmarshal yaml to config: %w", err)
+	}
+
+	config.Watchers, err = mergeWatchers(raw.Watchers, func(r yaml.Node, w *WatcherConfig) error {
+		return r.Decode(w)
+	})
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to unmarshal yaml to config: %w", err)
+	}
+
 	return config, nil
 }
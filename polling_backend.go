@@ -0,0 +1,207 @@
+package ev
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultPollInterval is the default interval in milliseconds between PollingBackend scans.
+const DefaultPollInterval = 500
+
+// PollingBackend is a Backend that periodically stats the entries of each watched directory
+// and diffs their mtime, size, and mode against the previous scan. Given to an EventEmitter, it
+// diffs against the emitter's file cache rather than keeping its own. Use it on file systems
+// where OS notifications are unreliable, such as bind-mounted Docker volumes, NFS or SMB shares,
+// and some FUSE mounts.
+type PollingBackend struct {
+	interval time.Duration
+	dirs     map[string]struct{}
+	cache    *fileCache
+	events   chan Event
+	errors   chan error
+	done     chan struct{}
+	once     sync.Once
+	mu       sync.Mutex
+}
+
+// NewPollingBackend returns a new PollingBackend that scans every intervalMs milliseconds.
+// If intervalMs is zero, DefaultPollInterval is used.
+func NewPollingBackend(intervalMs uint) *PollingBackend {
+	if intervalMs == 0 {
+		intervalMs = DefaultPollInterval
+	}
+
+	b := &PollingBackend{
+		interval: time.Duration(intervalMs) * time.Millisecond,
+		dirs:     make(map[string]struct{}),
+		cache:    newFileCache(),
+		events:   make(chan Event),
+		errors:   make(chan error),
+		done:     make(chan struct{}),
+	}
+
+	go b.run()
+
+	return b
+}
+
+// Add starts polling the entries of dir. Adding an already watched directory is a no-op.
+func (b *PollingBackend) Add(dir string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.dirs[dir]; ok {
+		return nil
+	}
+
+	entries, err := readEntries(dir)
+	if err != nil {
+		return err
+	}
+
+	b.dirs[dir] = struct{}{}
+	b.cache.setEntries(dir, entries)
+
+	return nil
+}
+
+// Remove stops polling the entries of dir.
+func (b *PollingBackend) Remove(dir string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.dirs[dir]; !ok {
+		return ErrNonExistentWatch
+	}
+
+	delete(b.dirs, dir)
+
+	return nil
+}
+
+// useCache replaces the backend's file cache with cache, shared with an EventEmitter.
+func (b *PollingBackend) useCache(cache *fileCache) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for dir := range b.dirs {
+		cache.setEntries(dir, b.cache.entries(dir))
+	}
+	b.cache = cache
+}
+
+// Events returns the channel that events are delivered on.
+func (b *PollingBackend) Events() <-chan Event { return b.events }
+
+// Errors returns the channel that scan errors are delivered on.
+func (b *PollingBackend) Errors() <-chan error { return b.errors }
+
+// Close stops polling and closes the backend's channels.
+func (b *PollingBackend) Close() error {
+	b.once.Do(func() { close(b.done) })
+	return nil
+}
+
+func (b *PollingBackend) run() {
+	defer close(b.errors)
+	defer close(b.events)
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+			events, errs := b.scan()
+
+			for _, event := range events {
+				select {
+				case b.events <- event:
+				case <-b.done:
+					return
+				}
+			}
+
+			for _, err := range errs {
+				select {
+				case b.errors <- err:
+				case <-b.done:
+					return
+				}
+			}
+		}
+	}
+}
+
+// scan diffs every watched directory against the cache, updating it and returning the events
+// found. Events are sent by the caller once the lock is released, since subscribers may Add.
+func (b *PollingBackend) scan() ([]Event, []error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var (
+		events []Event
+		errs   []error
+	)
+
+	for dir := range b.dirs {
+		current, err := readEntries(dir)
+		if err != nil {
+			// the parent directory's scan reports the removal.
+			if !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			delete(b.dirs, dir)
+			b.cache.delete(dir)
+			continue
+		}
+
+		previous := b.cache.entries(dir)
+
+		for path, info := range current {
+			old, ok := previous[path]
+			switch {
+			case !ok:
+				events = append(events, NewEvent(CREATE, path, info))
+			case !old.ModTime().Equal(info.ModTime()) || old.Size() != info.Size():
+				events = append(events, NewEvent(WRITE, path, info))
+			case old.Mode() != info.Mode():
+				events = append(events, NewEvent(CHMOD, path, info))
+			}
+		}
+
+		for path, info := range previous {
+			if _, ok := current[path]; !ok {
+				events = append(events, NewEvent(REMOVE, path, info))
+			}
+		}
+
+		b.cache.setEntries(dir, current)
+	}
+
+	return events, errs
+}
+
+func readEntries(dir string) (map[string]fs.FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make(map[string]fs.FileInfo, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue // removed between ReadDir and Info; picked up on the next scan.
+		}
+		infos[filepath.Join(dir, entry.Name())] = info
+	}
+
+	return infos, nil
+}
@@ -0,0 +1,203 @@
+package ev_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dimmerz92/eavesdrop/v2"
+)
+
+const pollInterval = 10
+
+func awaitBackendEvent(t *testing.T, b ev.Backend, path string, op ev.Op) {
+	t.Helper()
+	timeout := time.After(eventTimeout)
+	for {
+		select {
+		case e := <-b.Events():
+			if e.Path() == path && e.Has(op) {
+				return
+			}
+		case <-timeout:
+			t.Errorf("timed out waiting for %s on %s", op, path)
+			return
+		}
+	}
+}
+
+func TestPollingBackend_Events(t *testing.T) {
+	tests := []struct {
+		name     string
+		prepare  func(path string) error
+		trigger  func(path string) error
+		expected ev.Op
+	}{
+		{
+			name:     "new file emits CREATE",
+			trigger:  func(path string) error { return os.WriteFile(path, []byte("hello"), 0o644) },
+			expected: ev.CREATE,
+		},
+		{
+			name:     "size change emits WRITE",
+			prepare:  func(path string) error { return os.WriteFile(path, []byte("initial"), 0o644) },
+			trigger:  func(path string) error { return os.WriteFile(path, []byte("updated content"), 0o644) },
+			expected: ev.WRITE,
+		},
+		{
+			name:    "mtime change emits WRITE",
+			prepare: func(path string) error { return os.WriteFile(path, []byte("initial"), 0o644) },
+			trigger: func(path string) error {
+				return os.Chtimes(path, time.Time{}, time.Now().Add(time.Hour))
+			},
+			expected: ev.WRITE,
+		},
+		{
+			name:     "mode change emits CHMOD",
+			prepare:  func(path string) error { return os.WriteFile(path, []byte("initial"), 0o644) },
+			trigger:  func(path string) error { return os.Chmod(path, 0o600) },
+			expected: ev.CHMOD,
+		},
+		{
+			name:     "removed file emits REMOVE",
+			prepare:  func(path string) error { return os.WriteFile(path, []byte("bye"), 0o644) },
+			trigger:  func(path string) error { return os.Remove(path) },
+			expected: ev.REMOVE,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "file.go")
+
+			if test.prepare != nil {
+				if err := test.prepare(path); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			b := ev.NewPollingBackend(pollInterval)
+			defer b.Close()
+
+			if err := b.Add(dir); err != nil {
+				t.Fatalf("Add() = %v, expected nil", err)
+			}
+
+			if err := test.trigger(path); err != nil {
+				t.Fatal(err)
+			}
+
+			awaitBackendEvent(t, b, path, test.expected)
+		})
+	}
+}
+
+func TestPollingBackend_Remove(t *testing.T) {
+	dir := t.TempDir()
+	b := ev.NewPollingBackend(pollInterval)
+	defer b.Close()
+
+	if err := b.Remove(dir); !errors.Is(err, ev.ErrNonExistentWatch) {
+		t.Errorf("Remove() on unwatched dir = %v, expected ErrNonExistentWatch", err)
+	}
+
+	if err := b.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Remove(dir); err != nil {
+		t.Errorf("Remove() = %v, expected nil", err)
+	}
+}
+
+func TestPollingBackend_Close(t *testing.T) {
+	b := ev.NewPollingBackend(pollInterval)
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close() = %v, expected nil", err)
+	}
+
+	select {
+	case _, ok := <-b.Events():
+		if ok {
+			t.Error("received event after Close()")
+		}
+	case <-time.After(eventTimeout):
+		t.Error("events channel not closed after Close()")
+	}
+}
+
+func TestEventEmitter_Start_PollingBackend(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	w, ch := testWatcher(t, dir)
+	e := ev.NewEmitter(dir).WithBackend(ev.NewPollingBackend(pollInterval))
+	e.Subscribe(w)
+	e.Start(t.Context())
+
+	path := filepath.Join(dir, "sub", "new.go")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if event := awaitEvent(t, ch); event.Path() != path {
+		t.Errorf("event path = %q, expected %q", event.Path(), path)
+	}
+}
+
+type recorder struct {
+	mu     sync.Mutex
+	events []ev.Event
+}
+
+func (r *recorder) Handle(event ev.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) count(path string, op ev.Op) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, event := range r.events {
+		if event.Path() == path && event.Has(op) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestEventEmitter_Start_PollingBackend_SharedCache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "existing.go")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &recorder{}
+	e := ev.NewEmitter(dir).WithBackend(ev.NewPollingBackend(pollInterval))
+	e.Subscribe(r)
+	e.Start(t.Context())
+
+	time.Sleep(10 * pollInterval * time.Millisecond)
+	if n := r.count(path, ev.CREATE); n != 0 {
+		t.Errorf("existing file reported as created %d times", n)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * pollInterval * time.Millisecond)
+	if n := r.count(path, ev.REMOVE); n != 1 {
+		t.Errorf("removed file reported %d times, expected once", n)
+	}
+}
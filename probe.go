@@ -0,0 +1,193 @@
+package ev
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// DefaultProbeInterval is the default interval in milliseconds between readiness probe attempts.
+const DefaultProbeInterval = 100
+
+// Probe reports when a service is ready to receive traffic. Attach probes to a Watcher with
+// WithReadiness to hold back browser refreshes until the service is up.
+type Probe interface {
+	// Reset discards any readiness observed so far. Called before the service is restarted.
+	Reset()
+
+	// Wait blocks until the service is ready, or returns an error once ctx is done.
+	Wait(ctx context.Context) error
+}
+
+// TCPProbe is ready once a TCP connection to its address succeeds.
+type TCPProbe struct {
+	addr     string
+	interval time.Duration
+}
+
+// NewTCPProbe returns a TCPProbe that dials addr (e.g. "127.0.0.1:8000") every intervalMs
+// milliseconds. If intervalMs is zero, DefaultProbeInterval is used.
+func NewTCPProbe(addr string, intervalMs uint) *TCPProbe {
+	return &TCPProbe{addr: addr, interval: probeInterval(intervalMs)}
+}
+
+// Reset is a no-op; TCPProbe holds no state.
+func (p *TCPProbe) Reset() {}
+
+// Wait blocks until a connection to the probe address succeeds or ctx is done.
+func (p *TCPProbe) Wait(ctx context.Context) error {
+	var dialer net.Dialer
+	return poll(ctx, p.interval, func() error {
+		conn, err := dialer.DialContext(ctx, "tcp", p.addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+}
+
+// HTTPProbe is ready once a GET request to its URL returns a 2xx status.
+type HTTPProbe struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+}
+
+// NewHTTPProbe returns an HTTPProbe that requests url every intervalMs milliseconds.
+// If intervalMs is zero, DefaultProbeInterval is used.
+func NewHTTPProbe(url string, intervalMs uint) *HTTPProbe {
+	return &HTTPProbe{url: url, interval: probeInterval(intervalMs), client: &http.Client{}}
+}
+
+// Reset is a no-op; HTTPProbe holds no state.
+func (p *HTTPProbe) Reset() {}
+
+// Wait blocks until a GET request to the probe URL returns a 2xx status or ctx is done.
+func (p *HTTPProbe) Wait(ctx context.Context) error {
+	return poll(ctx, p.interval, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := p.client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("unexpected status: %s", resp.Status)
+		}
+
+		return nil
+	})
+}
+
+// LogProbe is ready once a line written to it matches its pattern. Attach it to a Shell with
+// WithServiceOutput so that it sees the service's output.
+type LogProbe struct {
+	pattern *regexp.Regexp
+	buf     []byte
+	ready   chan struct{}
+	matched bool
+	mu      sync.Mutex
+}
+
+// NewLogProbe returns a LogProbe that matches each output line against pattern
+// (e.g. "listening on"). Returns an error if pattern is not a valid regular expression.
+func NewLogProbe(pattern string) (*LogProbe, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LogProbe{pattern: re, ready: make(chan struct{})}, nil
+}
+
+// Write scans complete lines in b for the probe pattern. It never returns an error.
+func (p *LogProbe) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.matched {
+		return len(b), nil
+	}
+
+	p.buf = append(p.buf, b...)
+	for {
+		idx := bytes.IndexByte(p.buf, '\n')
+		if idx == -1 {
+			break
+		}
+
+		line := p.buf[:idx]
+		p.buf = p.buf[idx+1:]
+
+		if p.pattern.Match(line) {
+			p.matched = true
+			p.buf = nil
+			close(p.ready)
+			break
+		}
+	}
+
+	return len(b), nil
+}
+
+// Reset discards any match and buffered output.
+func (p *LogProbe) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf = nil
+	if p.matched {
+		p.matched = false
+		p.ready = make(chan struct{})
+	}
+}
+
+// Wait blocks until a line matching the probe pattern is written or ctx is done.
+func (p *LogProbe) Wait(ctx context.Context) error {
+	p.mu.Lock()
+	ready := p.ready
+	p.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("no output matched %q: %w", p.pattern, ctx.Err())
+	}
+}
+
+// poll calls check every interval until it succeeds, returning the last error if ctx is done first.
+func poll(ctx context.Context, interval time.Duration, check func() error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-ticker.C:
+		}
+	}
+}
+
+func probeInterval(intervalMs uint) time.Duration {
+	if intervalMs == 0 {
+		intervalMs = DefaultProbeInterval
+	}
+	return time.Duration(intervalMs) * time.Millisecond
+}
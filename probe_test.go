@@ -0,0 +1,207 @@
+package ev_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dimmerz92/eavesdrop/v2"
+)
+
+const (
+	probeInterval = 10
+	probeTimeout  = 200 * time.Millisecond
+)
+
+func TestTCPProbe_Wait(t *testing.T) {
+	t.Run("ready when port is open", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+
+		ctx, cancel := context.WithTimeout(t.Context(), probeTimeout)
+		defer cancel()
+
+		if err := ev.NewTCPProbe(ln.Addr().String(), probeInterval).Wait(ctx); err != nil {
+			t.Errorf("Wait() = %v, expected nil", err)
+		}
+	})
+
+	t.Run("times out when port is closed", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := ln.Addr().String()
+		ln.Close()
+
+		ctx, cancel := context.WithTimeout(t.Context(), probeTimeout)
+		defer cancel()
+
+		if err := ev.NewTCPProbe(addr, probeInterval).Wait(ctx); err == nil {
+			t.Error("Wait() = nil, expected timeout error")
+		}
+	})
+}
+
+func TestHTTPProbe_Wait(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    int32
+		status      int
+		expectedErr bool
+	}{
+		{name: "ready on 2xx", status: http.StatusNoContent},
+		{name: "ready after initial failures", failures: 3, status: http.StatusOK},
+		{name: "times out on non-2xx", status: http.StatusServiceUnavailable, expectedErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls atomic.Int32
+			app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) <= test.failures {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				w.WriteHeader(test.status)
+			}))
+			defer app.Close()
+
+			ctx, cancel := context.WithTimeout(t.Context(), probeTimeout)
+			defer cancel()
+
+			err := ev.NewHTTPProbe(app.URL, probeInterval).Wait(ctx)
+			if test.expectedErr && err == nil {
+				t.Error("Wait() = nil, expected error")
+			} else if !test.expectedErr && err != nil {
+				t.Errorf("Wait() = %v, expected nil", err)
+			}
+		})
+	}
+}
+
+func TestNewLogProbe(t *testing.T) {
+	if _, err := ev.NewLogProbe("listening on"); err != nil {
+		t.Errorf("NewLogProbe() = %v, expected nil", err)
+	}
+
+	if _, err := ev.NewLogProbe("(unclosed"); err == nil {
+		t.Error("NewLogProbe() = nil, expected error for invalid pattern")
+	}
+}
+
+func TestLogProbe_Wait(t *testing.T) {
+	tests := []struct {
+		name        string
+		writes      []string
+		expectedErr bool
+	}{
+		{name: "matching line", writes: []string{"starting\nlistening on :8000\n"}},
+		{name: "line split across writes", writes: []string{"listen", "ing on :8000", "\n"}},
+		{name: "no matching line", writes: []string{"starting\n", "still starting\n"}, expectedErr: true},
+		{name: "incomplete line not matched", writes: []string{"listening on :8000"}, expectedErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			probe, err := ev.NewLogProbe(`listening on :\d+`)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, write := range test.writes {
+				probe.Write([]byte(write))
+			}
+
+			ctx, cancel := context.WithTimeout(t.Context(), probeTimeout)
+			defer cancel()
+
+			err = probe.Wait(ctx)
+			if test.expectedErr && err == nil {
+				t.Error("Wait() = nil, expected error")
+			} else if !test.expectedErr && err != nil {
+				t.Errorf("Wait() = %v, expected nil", err)
+			}
+		})
+	}
+
+	t.Run("reset discards previous match", func(t *testing.T) {
+		probe, err := ev.NewLogProbe("ready")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		probe.Write([]byte("ready\n"))
+		probe.Reset()
+
+		ctx, cancel := context.WithTimeout(t.Context(), probeTimeout)
+		defer cancel()
+
+		go func() {
+			time.Sleep(probeTimeout / 4)
+			probe.Write([]byte("ready\n"))
+		}()
+
+		start := time.Now()
+		if err := probe.Wait(ctx); err != nil {
+			t.Fatalf("Wait() = %v, expected nil", err)
+		}
+		if time.Since(start) < probeTimeout/4 {
+			t.Error("Wait() returned before the service was ready again")
+		}
+	})
+}
+
+type mockProxy struct{ refreshed chan time.Time }
+
+func (m mockProxy) RefreshBrowser() { m.refreshed <- time.Now() }
+
+func TestWatcher_WithReadiness(t *testing.T) {
+	tests := []struct {
+		name       string
+		readyAfter time.Duration
+		timeoutMs  uint
+		minWait    time.Duration
+	}{
+		{name: "refresh waits for probe", readyAfter: 100 * time.Millisecond, timeoutMs: 1000, minWait: 100 * time.Millisecond},
+		{name: "refresh proceeds after timeout", readyAfter: time.Hour, timeoutMs: 50, minWait: 50 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			probe, err := ev.NewLogProbe("ready")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			proxy := mockProxy{refreshed: make(chan time.Time, 1)}
+			w := ev.NewWatcher(t.Name(), testRoot).
+				WithFiletypes(".go").
+				WithDebounceDelay(debounceDelay).
+				WithProxy(proxy, 0).
+				WithReadiness(test.timeoutMs, probe).
+				WithOnChange(func(_ ev.Event) {
+					time.AfterFunc(test.readyAfter, func() { probe.Write([]byte("ready\n")) })
+				})
+
+			start := time.Now()
+			w.Handle(fileEvent("main.go", ev.WRITE))
+
+			select {
+			case refreshed := <-proxy.refreshed:
+				if waited := refreshed.Sub(start); waited < test.minWait {
+					t.Errorf("refreshed after %v, expected at least %v", waited, test.minWait)
+				}
+			case <-time.After(eventTimeout):
+				t.Fatal("timed out waiting for refresh")
+			}
+		})
+	}
+}
@@ -2,29 +2,75 @@ package ev
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Shell struct {
 	ctx            context.Context
 	cmd            *exec.Cmd
-	pid            int
+	tasks          map[*exec.Cmd]struct{}
+	exited         chan struct{}
+	generation     uint64
 	prefix         string
 	flag           string
+	dir            string
+	env            []string
+	eventEnv       []string
+	stdout         io.Writer
+	stderr         io.Writer
+	serviceOutput  io.Writer
 	taskTimeout    time.Duration
 	serviceTimeout time.Duration
+	restart        RestartPolicy
+	maxRestarts    uint
+	backoff        time.Duration
+	maxBackoff     time.Duration
+	mu             sync.Mutex
 }
 
-// NewShell returns a Shell that invokes commands via the detected system shell
-// (e.g. /bin/sh on Unix, powershell.exe or cmd.exe on Windows).
-func NewShell(ctx context.Context, taskTimeoutMs, serviceTimeoutMs uint) *Shell {
+// ShellOption configures a Shell created by NewShell.
+type ShellOption func(*Shell)
+
+// WithInterpreter runs commands with the shell at path, passing each command after flag, e.g.
+// "bash" and "-c". If flag is empty, ShellFlag(path) is used.
+func WithInterpreter(path, flag string) ShellOption {
+	return func(s *Shell) {
+		if flag == "" {
+			flag = ShellFlag(path)
+		}
+		s.prefix = path
+		s.flag = flag
+	}
+}
+
+// WithWorkingDir runs commands in dir rather than the current working directory.
+func WithWorkingDir(dir string) ShellOption {
+	return func(s *Shell) {
+		s.dir = dir
+	}
+}
+
+// WithShellEnv adds environment variables in KEY=value form to every command, overriding the
+// process environment. Later variables override earlier ones.
+func WithShellEnv(env ...string) ShellOption {
+	return func(s *Shell) {
+		s.env = append(s.env, env...)
+	}
+}
+
+// NewShell returns a Shell that invokes commands via the default system shell
+// (/bin/sh on Unix, powershell.exe or cmd.exe on Windows), unless configured otherwise by opts.
+func NewShell(ctx context.Context, taskTimeoutMs, serviceTimeoutMs uint, opts ...ShellOption) *Shell {
 	prefix := DetectShell()
-	return &Shell{
+	s := &Shell{
 		ctx:            ctx,
 		prefix:         prefix,
 		flag:           ShellFlag(prefix),
@@ -32,89 +78,281 @@ func NewShell(ctx context.Context, taskTimeoutMs, serviceTimeoutMs uint) *Shell
 		serviceTimeout: time.Duration(serviceTimeoutMs) * (time.Millisecond),
 	}
 
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 // ExecAndWait runs task and blocks until it finishes or the task timeout
 // elapses.
 func (s *Shell) ExecAndWait(task string) error {
+	stdout, stderr := s.outputs()
+	return s.ExecAndWaitContext(s.ctx, stdout, stderr, task)
+}
+
+// ExecAndWaitContext is ExecAndWait, also killing the task when ctx is done, and writing its
+// output to stdout and stderr rather than the shell's output, so that tasks run concurrently keep
+// their output apart. A task whose ctx is already done is not started.
+func (s *Shell) ExecAndWaitContext(ctx context.Context, stdout, stderr io.Writer, task string) error {
 	if strings.TrimSpace(task) == "" {
 		return fmt.Errorf("cannot run blank task")
 	}
 
-	ctx, cancel := context.WithTimeout(s.ctx, s.taskTimeout)
+	ctx, cancel := s.taskContext(ctx)
+	defer cancel()
+
+	return s.wait(ctx, exec.CommandContext(ctx, s.prefix, s.flag, task), stdout, stderr)
+}
+
+// ExecArgsAndWait runs the program args[0] with the remaining arguments directly, without a
+// shell, and blocks until it finishes or the task timeout elapses. The program is looked up in
+// the PATH unless it contains a path separator.
+func (s *Shell) ExecArgsAndWait(args ...string) error {
+	stdout, stderr := s.outputs()
+	return s.ExecArgsAndWaitContext(s.ctx, stdout, stderr, args...)
+}
+
+// ExecArgsAndWaitContext is ExecArgsAndWait, also killing the task when ctx is done, and writing
+// its output to stdout and stderr rather than the shell's output.
+func (s *Shell) ExecArgsAndWaitContext(ctx context.Context, stdout, stderr io.Writer, args ...string) error {
+	if len(args) == 0 || strings.TrimSpace(args[0]) == "" {
+		return fmt.Errorf("cannot run blank task")
+	}
+
+	ctx, cancel := s.taskContext(ctx)
 	defer cancel()
 
-	s.cmd = exec.CommandContext(ctx, s.prefix, s.flag, task)
+	return s.wait(ctx, exec.CommandContext(ctx, args[0], args[1:]...), stdout, stderr)
+}
+
+// taskContext returns a context for a task, done when ctx or the shell's context is done, or the
+// task timeout elapses.
+func (s *Shell) taskContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, s.taskTimeout)
+	stop := context.AfterFunc(s.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// Quote returns arg quoted for the shell's interpreter, so that it reaches the command as a single
+// argument with no expansion: single quotes for PowerShell and POSIX shells, and double quotes for
+// cmd.exe, which cannot prevent %VAR% expansion.
+func (s *Shell) Quote(arg string) string {
+	switch strings.TrimSuffix(strings.ToLower(filepath.Base(s.prefix)), ".exe") {
+	case "cmd":
+		return `"` + strings.ReplaceAll(arg, `"`, `""`) + `"`
+	case "powershell", "pwsh":
+		return "'" + strings.ReplaceAll(arg, "'", "''") + "'"
+	default:
+		return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+}
 
-	s.ToProcessGroup()
-	s.cmd.Stdout = os.Stdout
-	s.cmd.Stderr = os.Stdout
+// wait runs cmd in its own process group, writing its output to stdout and stderr, and blocks
+// until it exits. If ctx is done first, the whole process group is killed.
+func (s *Shell) wait(ctx context.Context, cmd *exec.Cmd, stdout, stderr io.Writer) error {
+	toProcessGroup(cmd)
+	cmd.Dir = s.dir
+	s.mu.Lock()
+	cmd.Env = s.environ()
+	s.mu.Unlock()
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	cmd.Cancel = func() error { return killProcessGroup(cmd) }
 
 	errCh := make(chan error, 1)
 	go func() {
-		errCh <- s.cmd.Start()
+		errCh <- cmd.Start()
 	}()
 
 	select {
 	case <-ctx.Done():
-		return s.KillProcessGroup()
+		if err := <-errCh; err != nil {
+			return err
+		}
+		defer flush(stdout, stderr)
+		return cmd.Wait() // the context is done, so Wait cancels via killProcessGroup.
 	case err := <-errCh:
 		if err != nil {
 			return err
 		}
 	}
 
-	s.pid = s.cmd.Process.Pid
-	s.cmd.Cancel = func() error { return s.KillProcessGroup() }
+	s.mu.Lock()
+	s.cmd = cmd
+	if s.tasks == nil {
+		s.tasks = make(map[*exec.Cmd]struct{})
+	}
+	s.tasks[cmd] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.tasks, cmd)
+		s.mu.Unlock()
+	}()
 
-	return s.cmd.Wait()
+	defer flush(stdout, stderr)
+	return cmd.Wait()
 }
 
 // ExecAndReturn starts service as a background process and returns without
-// waiting.
+// waiting. If a restart policy is set, the service is restarted according to it
+// whenever it exits on its own.
 func (s *Shell) ExecAndReturn(service string) error {
 	if strings.TrimSpace(service) == "" {
 		return fmt.Errorf("cannot run blank task")
 	}
 
-	s.cmd = exec.CommandContext(s.ctx, s.prefix, s.flag, service)
+	s.mu.Lock()
+	s.generation++
+	generation := s.generation
+	s.mu.Unlock()
+
+	return s.startService(service, generation, 0)
+}
 
-	s.ToProcessGroup()
-	s.cmd.Stdout = os.Stdout
-	s.cmd.Stderr = os.Stdout
+// startService starts service unless it has been superseded or stopped since generation
+// began, then supervises it in the background.
+func (s *Shell) startService(service string, generation uint64, restarts uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	err := s.cmd.Start()
+	if s.generation != generation {
+		return nil
+	}
+
+	cmd := exec.CommandContext(s.ctx, s.prefix, s.flag, service)
+
+	stdout, stderr := s.outputs()
+	if s.serviceOutput != nil {
+		stdout = io.MultiWriter(stdout, s.serviceOutput)
+		stderr = io.MultiWriter(stderr, s.serviceOutput)
+	}
+
+	toProcessGroup(cmd)
+	cmd.Dir = s.dir
+	cmd.Env = s.environ()
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Start()
 	if err != nil {
 		return err
 	}
 
-	s.pid = s.cmd.Process.Pid
+	s.cmd = cmd
+	s.exited = make(chan struct{})
+
+	go s.supervise(cmd, s.exited, service, generation, restarts)
 
 	return nil
 }
 
+// current returns the most recently started command, which may be nil.
+func (s *Shell) current() *exec.Cmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cmd
+}
+
+// commands returns the most recently started command and every task still running.
+func (s *Shell) commands() []*exec.Cmd {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var cmds []*exec.Cmd
+	if s.cmd != nil {
+		cmds = append(cmds, s.cmd)
+	}
+	for cmd := range s.tasks {
+		if cmd != s.cmd {
+			cmds = append(cmds, cmd)
+		}
+	}
+	return cmds
+}
+
+// halt stops supervising the current service, so that exiting does not restart it.
+func (s *Shell) halt() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.generation++
+}
+
+// WithOutput directs the stdout and stderr of tasks and services to the given writers. By
+// default both are written to os.Stdout. Writers with a Flush method, such as line buffers,
+// are flushed whenever a command exits.
+func (s *Shell) WithOutput(stdout, stderr io.Writer) *Shell {
+	s.stdout = stdout
+	s.stderr = stderr
+	return s
+}
+
+// outputs returns the writers for a new command's stdout and stderr.
+func (s *Shell) outputs() (io.Writer, io.Writer) {
+	stdout, stderr := s.stdout, s.stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stdout
+	}
+	return stdout, stderr
+}
+
+// flush flushes any buffered command output in writers with a Flush method, such as line buffers.
+func flush(writers ...io.Writer) {
+	for _, w := range writers {
+		if f, ok := w.(interface{ Flush() error }); ok {
+			f.Flush()
+		}
+	}
+}
+
+// WithServiceOutput copies the output of services started with ExecAndReturn to w, in addition
+// to stdout. Use it to attach a LogProbe.
+func (s *Shell) WithServiceOutput(w io.Writer) *Shell {
+	s.serviceOutput = w
+	return s
+}
+
+// SetEventEnv sets environment variables in KEY=value form describing the change that
+// triggered a run. They are added to the environment of every subsequently started command,
+// replacing any previously set event variables.
+func (s *Shell) SetEventEnv(env ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.eventEnv = env
+}
+
+// environ returns the environment for a new command, or nil to inherit the process environment.
+// s.mu must be held, since a supervised service may be restarted while the event env is set.
+func (s *Shell) environ() []string {
+	if len(s.env) == 0 && len(s.eventEnv) == 0 {
+		return nil
+	}
+	return slices.Concat(os.Environ(), s.env, s.eventEnv)
+}
+
 // Stop gracefully shuts down the running service. Sends SIGTERM and waits up
 // to the service timeout before sending SIGKILL.
 func (s *Shell) Stop() error {
-	if s.cmd == nil || s.cmd.Process == nil {
+	s.mu.Lock()
+	exited := s.exited
+	s.mu.Unlock()
+
+	if exited == nil {
 		return nil
 	}
 
-	cmd := s.cmd
-	done := make(chan error, 1)
-	go func() { done <- cmd.Wait() }()
-
 	s.TerminateProcessGroup()
 
 	select {
-	case err := <-done:
-		if err != nil {
-			var exitErr *exec.ExitError
-			if !errors.As(err, &exitErr) {
-				return err
-			}
-		}
-
+	case <-exited:
 	case <-time.After(s.serviceTimeout):
 		err := s.KillProcessGroup()
 		if err != nil {
@@ -122,7 +360,10 @@ func (s *Shell) Stop() error {
 		}
 	}
 
+	s.mu.Lock()
 	s.cmd = nil
+	s.exited = nil
+	s.mu.Unlock()
 
 	return nil
 }
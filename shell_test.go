@@ -2,8 +2,11 @@ package ev_test
 
 import (
 	"bytes"
+	"context"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -59,6 +62,258 @@ func TestShell(t *testing.T) {
 		}
 	})
 
+	t.Run("SetEventEnv", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("uses sh variable expansion")
+		}
+
+		shell, r, w, restore := newShell()
+		defer restore()
+
+		shell.SetEventEnv("EAVESDROP_TEST=changed")
+
+		err := shell.ExecAndWait(`echo -n "$EAVESDROP_TEST"`)
+		w.Close()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var buf bytes.Buffer
+		buf.ReadFrom(r)
+
+		if stdout := buf.String(); stdout != "changed" {
+			t.Errorf("expected changed, got %s", stdout)
+		}
+	})
+
+	t.Run("WithShellEnv", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("uses sh variable expansion")
+		}
+
+		shell, r, w, restore := newShell()
+		defer restore()
+
+		shell = ev.NewShell(t.Context(), 50, 50, ev.WithShellEnv("EAVESDROP_TEST=env", "EAVESDROP_OTHER=other"))
+		shell.SetEventEnv("EAVESDROP_TEST=changed")
+
+		err := shell.ExecAndWait(`echo -n "$EAVESDROP_TEST $EAVESDROP_OTHER"`)
+		w.Close()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var buf bytes.Buffer
+		buf.ReadFrom(r)
+
+		if stdout := buf.String(); stdout != "changed other" {
+			t.Errorf("expected changed other, got %s", stdout)
+		}
+	})
+
+	t.Run("ExecArgsAndWait", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("uses printf")
+		}
+
+		tests := []struct {
+			name     string
+			args     []string
+			expected string
+			err      bool
+		}{
+			{name: "no args", err: true},
+			{name: "blank program", args: []string{" "}, err: true},
+			{name: "args are not split or expanded", args: []string{"printf", "%s|%s", "a b", "$HOME"}, expected: "a b|$HOME"},
+			{name: "missing program", args: []string{"eavesdrop-does-not-exist"}, err: true},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				var stdout bytes.Buffer
+				shell := ev.NewShell(t.Context(), 500, 50).WithOutput(&stdout, &stdout)
+
+				err := shell.ExecArgsAndWait(test.args...)
+				if test.err && err == nil {
+					t.Error("expected error")
+				} else if !test.err && err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+
+				if got := stdout.String(); got != test.expected {
+					t.Errorf("expected %s, got %s", test.expected, got)
+				}
+			})
+		}
+	})
+
+	t.Run("WithWorkingDir", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("uses sh builtins")
+		}
+
+		dir := t.TempDir()
+
+		var stdout bytes.Buffer
+		shell := ev.NewShell(t.Context(), 50, 50, ev.WithWorkingDir(dir)).WithOutput(&stdout, &stdout)
+
+		if err := shell.ExecAndWait("pwd"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		got, err := filepath.EvalSymlinks(strings.TrimSpace(stdout.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != expected {
+			t.Errorf("expected %s, got %s", expected, got)
+		}
+	})
+
+	t.Run("WithInterpreter", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("uses sh")
+		}
+
+		var stdout bytes.Buffer
+		shell := ev.NewShell(t.Context(), 50, 50, ev.WithInterpreter("sh", "")).WithOutput(&stdout, &stdout)
+
+		if err := shell.ExecAndWait("echo -n $0"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := stdout.String(); got != "sh" {
+			t.Errorf("expected sh, got %s", got)
+		}
+	})
+
+	t.Run("Quote", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("uses sh")
+		}
+
+		args := []string{
+			"plain.go",
+			"with space.go",
+			"a;rm -rf ~.go",
+			"$(touch pwned).go",
+			"`id`.go",
+			"it's.go",
+			"$HOME \\ \"quoted\".go",
+			"",
+		}
+
+		for _, arg := range args {
+			t.Run(arg, func(t *testing.T) {
+				var stdout bytes.Buffer
+				shell := ev.NewShell(t.Context(), 50, 50, ev.WithWorkingDir(t.TempDir())).WithOutput(&stdout, &stdout)
+
+				if err := shell.ExecAndWait("printf '%s|' " + shell.Quote(arg)); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if got := stdout.String(); got != arg+"|" {
+					t.Errorf("expected %q, got %q", arg+"|", got)
+				}
+			})
+		}
+	})
+
+	t.Run("WithOutput", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("uses sh redirection")
+		}
+
+		var stdout, stderr bytes.Buffer
+		shell := ev.NewShell(t.Context(), 50, 50).WithOutput(&stdout, &stderr)
+
+		err := shell.ExecAndWait("echo -n out; echo -n err >&2")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if stdout.String() != "out" {
+			t.Errorf("expected stdout out, got %s", stdout.String())
+		}
+
+		if stderr.String() != "err" {
+			t.Errorf("expected stderr err, got %s", stderr.String())
+		}
+	})
+
+	t.Run("ExecAndWaitContext", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("uses sh redirection")
+		}
+
+		var shared, stdout, stderr bytes.Buffer
+		shell := ev.NewShell(t.Context(), 50, 50).WithOutput(&shared, &shared)
+
+		if err := shell.ExecAndWaitContext(t.Context(), &stdout, &stderr, "echo -n out; echo -n err >&2"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := shell.ExecArgsAndWaitContext(t.Context(), &stdout, &stderr, "echo", "-n", " args"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if stdout.String() != "out args" {
+			t.Errorf("expected stdout %q, got %q", "out args", stdout.String())
+		}
+		if stderr.String() != "err" {
+			t.Errorf("expected stderr err, got %s", stderr.String())
+		}
+		if shared.Len() != 0 {
+			t.Errorf("expected no output to the shell's writers, got %q", shared.String())
+		}
+
+		t.Run("cancelled", func(t *testing.T) {
+			shell := ev.NewShell(t.Context(), 5000, 50)
+
+			ctx, cancel := context.WithCancel(t.Context())
+			time.AfterFunc(50*time.Millisecond, cancel)
+
+			start := time.Now()
+			if err := shell.ExecAndWaitContext(ctx, &stdout, &stderr, "sleep 5"); err == nil {
+				t.Error("expected error")
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("task ran for %v after its context was cancelled", elapsed)
+			}
+
+			if err := shell.ExecArgsAndWaitContext(ctx, &stdout, &stderr, "echo", "started"); err == nil {
+				t.Error("expected error starting a task with a done context")
+			}
+		})
+	})
+
+	t.Run("WithServiceOutput", func(t *testing.T) {
+		probe, err := ev.NewLogProbe("listening")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		shell, _, w, restore := newShell()
+		defer restore()
+		defer w.Close()
+
+		err = shell.WithServiceOutput(probe).ExecAndReturn("echo listening")
+		if err != nil {
+			t.Fatalf("failed to run service: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+		defer cancel()
+
+		if err := probe.Wait(ctx); err != nil {
+			t.Errorf("service output not copied: %v", err)
+		}
+	})
+
 	t.Run("ExecAndReturn terminations", func(t *testing.T) {
 		t.Run("TerminateProcessGroup", func(t *testing.T) {
 			service := `trap "exit 0" TERM; while true; do sleep 100; done`
@@ -102,6 +357,36 @@ func TestShell(t *testing.T) {
 			}
 		})
 
+		t.Run("KillProcessGroup cancels running tasks", func(t *testing.T) {
+			if runtime.GOOS == "windows" {
+				t.Skip("uses sleep")
+			}
+
+			shell := ev.NewShell(t.Context(), 10000, 50)
+
+			errs := make(chan error, 2)
+			for range 2 {
+				go func() { errs <- shell.ExecAndWait("sleep 10") }()
+			}
+
+			time.Sleep(100 * time.Millisecond)
+
+			if err := shell.KillProcessGroup(); err != nil {
+				t.Fatalf("failed to kill tasks: %v", err)
+			}
+
+			for range 2 {
+				select {
+				case err := <-errs:
+					if err == nil {
+						t.Error("expected killed task to return an error")
+					}
+				case <-time.After(2 * time.Second):
+					t.Fatal("task was not killed")
+				}
+			}
+		})
+
 		t.Run("StopService", func(t *testing.T) {
 			service := `trap "" TERM; while true; do sleep 1; done`
 			if runtime.GOOS == "windows" {
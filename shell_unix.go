@@ -5,21 +5,16 @@ package ev
 import (
 	"errors"
 	"fmt"
-	"os"
+	"os/exec"
 	"syscall"
 )
 
 var defaultShell = "/bin/sh"
 
-// DetectShell attempts to determine the shell environment from the $SHELL
-// environment variable, otherwise fallse back to /bin/sh
+// DetectShell returns /bin/sh. The user's $SHELL is deliberately ignored, as it may not be
+// POSIX compatible (e.g. fish); use WithInterpreter to run commands with another shell.
 func DetectShell() string {
-	prefix := os.Getenv("SHELL")
-	if prefix == "" {
-		prefix = defaultShell
-	}
-
-	return prefix
+	return defaultShell
 }
 
 // ShellFlag always returns -c.
@@ -30,20 +25,46 @@ func ShellFlag(prefix string) string {
 
 // ToProcessGroup sets the shell with a flag to spawn a new process group.
 func (s *Shell) ToProcessGroup() error {
-	if s.cmd == nil {
+	cmd := s.current()
+	if cmd == nil {
 		return fmt.Errorf("nil shell")
 	}
-	s.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	toProcessGroup(cmd)
 	return nil
 }
 
 // SignalProcessGroup sends the given signal to the shell.
 func (s *Shell) SignalProcessGroup(signal syscall.Signal) error {
-	if s.cmd == nil || s.cmd.Process == nil {
+	return signalProcessGroup(s.current(), signal)
+}
+
+// TerminateProcessGroup sends a SIGTERM to the shell.
+func (s *Shell) TerminateProcessGroup() error {
+	s.halt()
+	return s.SignalProcessGroup(syscall.SIGTERM)
+}
+
+// KillProcessGroup sends a SIGKILL to the shell and any tasks it is running.
+func (s *Shell) KillProcessGroup() error {
+	s.halt()
+
+	var errs []error
+	for _, cmd := range s.commands() {
+		errs = append(errs, signalProcessGroup(cmd, syscall.SIGKILL))
+	}
+	return errors.Join(errs...)
+}
+
+func toProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func signalProcessGroup(cmd *exec.Cmd, signal syscall.Signal) error {
+	if cmd == nil || cmd.Process == nil {
 		return nil
 	}
 
-	pgid, err := syscall.Getpgid(s.pid)
+	pgid, err := syscall.Getpgid(cmd.Process.Pid)
 	if err != nil {
 		if errors.Is(err, syscall.ESRCH) {
 			err = nil
@@ -54,12 +75,6 @@ func (s *Shell) SignalProcessGroup(signal syscall.Signal) error {
 	return syscall.Kill(-pgid, signal)
 }
 
-// TerminateProcessGroup sends a SIGTERM to the shell.
-func (s *Shell) TerminateProcessGroup() error {
-	return s.SignalProcessGroup(syscall.SIGTERM)
-}
-
-// KillProcessGroup sends a SIGKILL to the shell.
-func (s *Shell) KillProcessGroup() error {
-	return s.SignalProcessGroup(syscall.SIGKILL)
+func killProcessGroup(cmd *exec.Cmd) error {
+	return signalProcessGroup(cmd, syscall.SIGKILL)
 }
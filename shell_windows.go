@@ -37,29 +37,54 @@ func ShellFlag(prefix string) string {
 
 // ToProcessGroup sets the shell with a flag to spawn a new process group.
 func (s *Shell) ToProcessGroup() error {
-	if s.cmd == nil {
+	cmd := s.current()
+	if cmd == nil {
 		return fmt.Errorf("nil shell")
 	}
-
-	s.cmd.SysProcAttr = &syscall.SysProcAttr{
-		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP,
-	}
-
+	toProcessGroup(cmd)
 	return nil
 }
 
 // TerminateProcessGroup sends a CTRL_BREAK_EVENT to the shell.
 func (s *Shell) TerminateProcessGroup() error {
-	return windows.GenerateConsoleCtrlEvent(windows.CTRL_BREAK_EVENT, uint32(s.pid))
+	s.halt()
+
+	cmd := s.current()
+	if cmd == nil || cmd.Process == nil {
+		return nil
+	}
+
+	return windows.GenerateConsoleCtrlEvent(windows.CTRL_BREAK_EVENT, uint32(cmd.Process.Pid))
 }
 
-// KillProcessGroup uses taskkill to kill the underlying process group.
+// KillProcessGroup uses taskkill to kill the underlying process group and those of any tasks
+// the shell is running.
 func (s *Shell) KillProcessGroup() error {
-	pid := strconv.Itoa(s.pid)
-	cmd := exec.Command("taskkill", "/F", "/T", "/PID", pid)
-	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	s.halt()
+
+	var errs []error
+	for _, cmd := range s.commands() {
+		errs = append(errs, killProcessGroup(cmd))
+	}
+	return errors.Join(errs...)
+}
+
+func toProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP,
+	}
+}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd == nil || cmd.Process == nil {
+		return nil
+	}
+
+	pid := strconv.Itoa(cmd.Process.Pid)
+	kill := exec.Command("taskkill", "/F", "/T", "/PID", pid)
+	kill.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
 
-	err := cmd.Run()
+	err := kill.Run()
 	if err != nil {
 		var exitErr *exec.ExitError
 		if errors.As(err, &exitErr) && exitErr.ExitCode() == PROCESS_NOT_FOUND {
@@ -0,0 +1,137 @@
+package ev
+
+import (
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultMaxBackoff is the default cap in milliseconds on the delay between service restarts.
+const DefaultMaxBackoff = 30000
+
+// RestartPolicy determines whether a service that exits on its own is restarted.
+type RestartPolicy uint8
+
+const (
+	RESTART_NEVER      RestartPolicy = iota // never restart the service
+	RESTART_ON_FAILURE                      // restart the service if it exits with a non-zero code
+	RESTART_ALWAYS                          // restart the service whenever it exits
+)
+
+// RestartPolicyFromString parses "never", "on-failure", or "always", case-insensitively.
+// Any other value returns RESTART_NEVER.
+func RestartPolicyFromString(policy string) RestartPolicy {
+	switch strings.ToLower(policy) {
+	case "on-failure":
+		return RESTART_ON_FAILURE
+	case "always":
+		return RESTART_ALWAYS
+	default:
+		return RESTART_NEVER
+	}
+}
+
+// String returns the restart policy as a string.
+func (p RestartPolicy) String() string {
+	switch p {
+	case RESTART_ON_FAILURE:
+		return "on-failure"
+	case RESTART_ALWAYS:
+		return "always"
+	default:
+		return "never"
+	}
+}
+
+// shouldRestart reports whether a service that exited with code should be restarted.
+func (p RestartPolicy) shouldRestart(code int) bool {
+	switch p {
+	case RESTART_ON_FAILURE:
+		return code != 0
+	case RESTART_ALWAYS:
+		return true
+	default:
+		return false
+	}
+}
+
+// WithRestartPolicy supervises services started with ExecAndReturn, restarting them according
+// to policy when they exit on their own. Services stopped via Stop, TerminateProcessGroup, or
+// KillProcessGroup are never restarted. The delay before each restart starts at backoffMs and
+// doubles up to maxBackoffMs (DefaultMaxBackoff if zero). After maxRestarts consecutive restarts
+// the service is left stopped; zero allows unlimited restarts. A service that stays up for at
+// least maxBackoffMs before exiting is considered recovered, resetting the restart count and
+// backoff.
+func (s *Shell) WithRestartPolicy(policy RestartPolicy, maxRestarts, backoffMs, maxBackoffMs uint) *Shell {
+	if maxBackoffMs == 0 {
+		maxBackoffMs = DefaultMaxBackoff
+	}
+
+	s.restart = policy
+	s.maxRestarts = maxRestarts
+	s.backoff = time.Duration(backoffMs) * time.Millisecond
+	s.maxBackoff = time.Duration(maxBackoffMs) * time.Millisecond
+
+	return s
+}
+
+// supervise waits for a service to exit, reports its exit code, and restarts it if the restart
+// policy allows and the service was not stopped deliberately.
+func (s *Shell) supervise(cmd *exec.Cmd, exited chan struct{}, service string, generation uint64, restarts uint) {
+	started := time.Now()
+	_ = cmd.Wait()
+	if time.Since(started) >= s.maxBackoff {
+		restarts = 0
+	}
+
+	flush(s.stdout, s.stderr)
+	close(exited)
+
+	s.mu.Lock()
+	stopped := s.generation != generation
+	s.mu.Unlock()
+
+	if stopped || s.ctx.Err() != nil {
+		return
+	}
+
+	code := cmd.ProcessState.ExitCode()
+	slog.Warn("service exited", slog.String("service", service), slog.Int("code", code))
+
+	if !s.restart.shouldRestart(code) {
+		return
+	}
+
+	if s.maxRestarts > 0 && restarts >= s.maxRestarts {
+		slog.Error("service restart limit reached", slog.String("service", service), slog.Uint64("restarts", uint64(restarts)))
+		return
+	}
+
+	delay := s.backoffFor(restarts)
+	slog.Info("restarting service", slog.String("service", service), slog.Duration("backoff", delay))
+
+	select {
+	case <-s.ctx.Done():
+		return
+	case <-time.After(delay):
+	}
+
+	err := s.startService(service, generation, restarts+1)
+	if err != nil {
+		slog.Error("failed to restart service", slog.String("service", service), slog.Any("error", err))
+	}
+}
+
+// backoffFor returns the delay before the given restart, doubling from the initial backoff
+// up to the maximum.
+func (s *Shell) backoffFor(restarts uint) time.Duration {
+	delay := s.backoff
+	for range restarts {
+		delay *= 2
+		if delay >= s.maxBackoff {
+			return s.maxBackoff
+		}
+	}
+	return min(delay, s.maxBackoff)
+}
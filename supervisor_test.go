@@ -0,0 +1,149 @@
+package ev_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimmerz92/eavesdrop/v2"
+)
+
+func TestRestartPolicyFromString(t *testing.T) {
+	tests := []struct {
+		policy   string
+		expected ev.RestartPolicy
+	}{
+		{"never", ev.RESTART_NEVER},
+		{"on-failure", ev.RESTART_ON_FAILURE},
+		{"ON-FAILURE", ev.RESTART_ON_FAILURE},
+		{"always", ev.RESTART_ALWAYS},
+		{"", ev.RESTART_NEVER},
+		{"sometimes", ev.RESTART_NEVER},
+	}
+
+	for _, test := range tests {
+		t.Run(test.policy, func(t *testing.T) {
+			if got := ev.RestartPolicyFromString(test.policy); got != test.expected {
+				t.Errorf("RestartPolicyFromString(%q) = %v, expected %v", test.policy, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestShell_WithRestartPolicy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh redirection")
+	}
+
+	tests := []struct {
+		name         string
+		policy       ev.RestartPolicy
+		exitCode     string
+		maxRestarts  uint
+		expectedRuns int
+	}{
+		{name: "never does not restart", policy: ev.RESTART_NEVER, exitCode: "1", maxRestarts: 2, expectedRuns: 1},
+		{name: "on-failure restarts non-zero exit", policy: ev.RESTART_ON_FAILURE, exitCode: "1", maxRestarts: 2, expectedRuns: 3},
+		{name: "on-failure ignores zero exit", policy: ev.RESTART_ON_FAILURE, exitCode: "0", maxRestarts: 2, expectedRuns: 1},
+		{name: "always restarts zero exit", policy: ev.RESTART_ALWAYS, exitCode: "0", maxRestarts: 2, expectedRuns: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runs := filepath.Join(t.TempDir(), "runs")
+
+			shell := ev.NewShell(t.Context(), 50, 50).
+				WithRestartPolicy(test.policy, test.maxRestarts, 10, 1000)
+
+			err := shell.ExecAndReturn("echo run >> " + runs + "; exit " + test.exitCode)
+			if err != nil {
+				t.Fatalf("failed to run service: %v", err)
+			}
+
+			time.Sleep(300 * time.Millisecond)
+
+			if got := countRuns(t, runs); got != test.expectedRuns {
+				t.Errorf("service ran %d time(s), expected %d", got, test.expectedRuns)
+			}
+		})
+	}
+
+	t.Run("restart count resets once the service stays up", func(t *testing.T) {
+		runs := filepath.Join(t.TempDir(), "runs")
+
+		// each run outlasts the max backoff, so the single allowed restart is never used up.
+		shell := ev.NewShell(t.Context(), 50, 50).
+			WithRestartPolicy(ev.RESTART_ALWAYS, 1, 10, 50)
+
+		err := shell.ExecAndReturn("echo run >> " + runs + "; sleep 0.1")
+		if err != nil {
+			t.Fatalf("failed to run service: %v", err)
+		}
+
+		time.Sleep(500 * time.Millisecond)
+
+		if got := countRuns(t, runs); got < 3 {
+			t.Errorf("service ran %d time(s), expected at least 3", got)
+		}
+	})
+
+	t.Run("deliberate kill is not restarted", func(t *testing.T) {
+		runs := filepath.Join(t.TempDir(), "runs")
+
+		shell := ev.NewShell(t.Context(), 50, 50).
+			WithRestartPolicy(ev.RESTART_ALWAYS, 0, 10, 20)
+
+		err := shell.ExecAndReturn("echo run >> " + runs + "; sleep 100")
+		if err != nil {
+			t.Fatalf("failed to run service: %v", err)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+
+		if err := shell.KillProcessGroup(); err != nil {
+			t.Fatalf("failed to kill service: %v", err)
+		}
+
+		time.Sleep(200 * time.Millisecond)
+
+		if got := countRuns(t, runs); got != 1 {
+			t.Errorf("service ran %d time(s), expected 1", got)
+		}
+	})
+}
+
+func TestShell_WithRestartPolicy_SetEventEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	// run with -race: restarts read the event env while a runner sets it.
+	shell := ev.NewShell(t.Context(), 50, 50).
+		WithRestartPolicy(ev.RESTART_ALWAYS, 0, 1, 1)
+
+	if err := shell.ExecAndReturn("exit 1"); err != nil {
+		t.Fatalf("failed to run service: %v", err)
+	}
+
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for i := 0; time.Now().Before(deadline); i++ {
+		shell.SetEventEnv(fmt.Sprintf("EAVESDROP_PATH=%d.go", i))
+	}
+
+	if err := shell.KillProcessGroup(); err != nil {
+		t.Fatalf("failed to kill service: %v", err)
+	}
+}
+
+func countRuns(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read runs: %v", err)
+	}
+	return strings.Count(string(data), "run")
+}
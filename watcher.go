@@ -1,9 +1,11 @@
 package ev
 
 import (
+	"context"
 	"log/slog"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dimmerz92/eavesdrop/v2/internal/components"
@@ -12,12 +14,21 @@ import (
 // DefaultDebounceDelay is the default debounce delay in milliseconds applied to file change events.
 const DefaultDebounceDelay = 100
 
-var watcherRegistry = map[string]struct{}{}
+var (
+	watcherRegistry   = map[string]struct{}{}
+	watcherRegistryMu sync.Mutex
+)
 
 type Proxy interface {
 	RefreshBrowser()
 }
 
+// StylesheetProxy is a Proxy that can also swap a changed stylesheet in place, keeping page state.
+type StylesheetProxy interface {
+	Proxy
+	RefreshCSS(path string)
+}
+
 // Watcher is a profile that defines which file system events to respond to and how.
 // Add it to an EventEmitter to begin receiving events. Configure it with the With* builder methods.
 type Watcher struct {
@@ -26,16 +37,32 @@ type Watcher struct {
 	filetypes      components.Set[string]
 	dirs           components.Set[string]
 	files          components.Set[string]
+	globs          []string
 	onChange       func(Event)
+	onBatch        func([]Event) bool
+	pending        []Event
+	pendingIdx     map[string]int
+	mu             sync.Mutex
 	triggerRefresh bool
 	refreshDelay   time.Duration
+	probes         []Probe
+	probeTimeout   time.Duration
 	proxy          Proxy
+	cssFiletypes   components.Set[string]
 	debouncer      *components.Debouncer
 	excluder       *Excluder
+	ignoreOwn      bool
+	ownGrace       time.Duration
+	runs           int                    // handler calls in progress, guarded by mu
+	runEnded       time.Time              // when the last handler call returned, guarded by mu
+	runBatch       components.Set[string] // paths that started the latest run, guarded by mu
+	ownChanges     components.Set[string] // paths changed during the latest run, guarded by mu
+	prevChanges    components.Set[string] // paths changed during the run before, guarded by mu
+	ownOutputs     components.Set[string] // paths found re-triggering the watcher, guarded by mu
 }
 
-// NewWatcher returns a new Watcher profile rooted at root. name must be unique across all watchers
-// in the process. If root is empty, it defaults to the current directory.
+// NewWatcher returns a new Watcher profile rooted at root. name must be unique across all open
+// watchers in the process; Close releases it. If root is empty, it defaults to the current directory.
 // Panics if name is empty or already registered.
 func NewWatcher(name, root string) *Watcher {
 	name = strings.TrimSpace(name)
@@ -43,6 +70,9 @@ func NewWatcher(name, root string) *Watcher {
 		panic("watcher requires a non empty name")
 	}
 
+	watcherRegistryMu.Lock()
+	defer watcherRegistryMu.Unlock()
+
 	if _, ok := watcherRegistry[name]; ok {
 		panic("watcher requires a unique name: " + name)
 	}
@@ -54,18 +84,24 @@ func NewWatcher(name, root string) *Watcher {
 	}
 
 	return &Watcher{
-		name:      name,
-		root:      root,
-		filetypes: make(components.Set[string]),
-		dirs:      make(components.Set[string]),
-		files:     make(components.Set[string]),
-		onChange:  func(_ Event) { slog.Warn("default handler", slog.String("watcher", name)) },
-		debouncer: components.NewDebouncer(DefaultDebounceDelay),
+		name:         name,
+		root:         root,
+		filetypes:    make(components.Set[string]),
+		dirs:         make(components.Set[string]),
+		files:        make(components.Set[string]),
+		cssFiletypes: make(components.Set[string]),
+		onChange:     func(_ Event) { slog.Warn("default handler", slog.String("watcher", name)) },
+		pendingIdx:   make(map[string]int),
+		debouncer:    components.NewDebouncer(DefaultDebounceDelay),
+		runBatch:     make(components.Set[string]),
+		ownChanges:   make(components.Set[string]),
+		prevChanges:  make(components.Set[string]),
+		ownOutputs:   make(components.Set[string]),
 	}
 }
 
-// Handle processes an event, calling the onChange handler if the event is watched and not excluded.
-// Called by the EventEmitter; not intended for direct use.
+// Handle processes an event, calling the onChange (or onBatch) handler if the event is watched
+// and not excluded. Called by the EventEmitter; not intended for direct use.
 func (w *Watcher) Handle(event Event) {
 	if !w.Watched(event) {
 		return
@@ -74,17 +110,209 @@ func (w *Watcher) Handle(event Event) {
 		return
 	}
 
+	w.recordOwnChange(event)
+	w.enqueue(event)
+
 	w.debouncer.Do(func() {
-		slog.Info("file changed", slog.String("watcher", w.name), slog.String("path", event.Path()))
-		w.onChange(event)
-		if w.triggerRefresh {
-			time.Sleep(w.refreshDelay)
-			w.proxy.RefreshBrowser()
+		batch := w.dropOwnChanges(w.drain())
+		if len(batch) == 0 {
+			return
+		}
+
+		slog.Info("file changed", slog.String("watcher", w.name), slog.String("path", batch[len(batch)-1].Path()), slog.Int("changes", len(batch)))
+		w.fire(batch)
+	})
+}
+
+// fire calls the onChange (or onBatch) handler for batch, then refreshes the browser once the
+// readiness probes pass, if a Proxy is configured. An empty batch calls onChange with an empty
+// event and always reloads the page.
+func (w *Watcher) fire(batch []Event) {
+	var event Event
+	if len(batch) > 0 {
+		event = batch[len(batch)-1]
+	}
+
+	for _, probe := range w.probes {
+		probe.Reset()
+	}
+
+	ready := true
+	w.run(batch, func() {
+		if w.onBatch != nil {
+			ready = w.onBatch(batch)
+		} else {
+			w.onChange(event)
 		}
 	})
+
+	if w.triggerRefresh {
+		time.Sleep(w.refreshDelay)
+		if ready {
+			w.awaitReady()
+		}
+		w.refresh(batch)
+	}
+}
+
+// refresh swaps the changed stylesheets in place if every change in batch is to a CSS filetype
+// and the proxy supports it, and reloads the page otherwise.
+func (w *Watcher) refresh(batch []Event) {
+	proxy, ok := w.proxy.(StylesheetProxy)
+	if !ok || !w.stylesheetsOnly(batch) {
+		w.proxy.RefreshBrowser()
+		return
+	}
+
+	for _, event := range batch {
+		proxy.RefreshCSS(event.Path())
+	}
+}
+
+// stylesheetsOnly reports whether batch consists only of created or modified CSS filetypes.
+func (w *Watcher) stylesheetsOnly(batch []Event) bool {
+	if len(batch) == 0 {
+		return false
+	}
+
+	for _, event := range batch {
+		if _, ok := w.cssFiletypes[filepath.Ext(event.Path())]; !ok || event.Has(REMOVE|RENAME) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// awaitReady blocks until every readiness probe succeeds or the probe timeout elapses.
+func (w *Watcher) awaitReady() {
+	if len(w.probes) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), w.probeTimeout)
+	defer cancel()
+
+	for _, probe := range w.probes {
+		err := probe.Wait(ctx)
+		if err != nil {
+			slog.Warn("service not ready, refreshing anyway", slog.String("watcher", w.name), slog.Any("error", err))
+			return
+		}
+	}
+}
+
+// run calls fn, the watcher's handler for batch, tracking the run so changes it makes to watched
+// files can be told apart from others.
+func (w *Watcher) run(batch []Event, fn func()) {
+	w.mu.Lock()
+	w.prevChanges, w.ownChanges = w.ownChanges, w.prevChanges
+	clear(w.ownChanges)
+	clear(w.runBatch)
+	for _, event := range batch {
+		w.runBatch[event.Path()] = struct{}{}
+	}
+	w.runs++
+	w.mu.Unlock()
+
+	defer func() {
+		w.mu.Lock()
+		w.runs--
+		w.runEnded = time.Now()
+		w.mu.Unlock()
+	}()
+
+	fn()
+}
+
+// recordOwnChange records the path of event if it changed while the handler was running, or
+// within the grace period after it returned without being modified since. Otherwise the change
+// was not the handler's, and the path is forgotten.
+func (w *Watcher) recordOwnChange(event Event) {
+	if !w.ignoreOwn || event.Info() == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.runs == 0 {
+		if time.Since(w.runEnded) > w.ownGrace || event.Info().ModTime().After(w.runEnded) {
+			delete(w.ownChanges, event.Path())
+			return
+		}
+	}
+	w.ownChanges[event.Path()] = struct{}{}
 }
 
-// Watched reports whether the event matches this watcher's root, filetypes, files, or dirs.
+// dropOwnChanges removes the changes the watcher's own handler made from batch. A path is the
+// handler's own once it loops: changed during a run, it started the next run, which changed it
+// again. Such paths are logged when found, and their changes during later runs dropped too.
+// Changes to any other path, or made to an own path between runs, are kept.
+func (w *Watcher) dropOwnChanges(batch []Event) []Event {
+	if !w.ignoreOwn {
+		return batch
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	kept := batch[:0]
+	for _, event := range batch {
+		path := event.Path()
+
+		if _, changed := w.ownChanges[path]; !changed {
+			kept = append(kept, event)
+			continue
+		}
+
+		if _, known := w.ownOutputs[path]; !known {
+			_, started := w.runBatch[path]
+			_, changedBefore := w.prevChanges[path]
+			if !started || !changedBefore {
+				kept = append(kept, event)
+				continue
+			}
+
+			slog.Warn("rebuild loop detected, ignoring changes the watcher makes to this path while it runs",
+				slog.String("watcher", w.name), slog.String("path", path))
+			w.ownOutputs[path] = struct{}{}
+		}
+	}
+
+	return kept
+}
+
+// enqueue adds event to the pending batch, merging it with any earlier event for the same path.
+func (w *Watcher) enqueue(event Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if idx, ok := w.pendingIdx[event.path]; ok {
+		w.pending[idx].op |= event.op
+		if event.info != nil {
+			w.pending[idx].info = event.info
+		}
+		return
+	}
+
+	w.pendingIdx[event.path] = len(w.pending)
+	w.pending = append(w.pending, event)
+}
+
+// drain returns the pending batch in order of first occurrence and resets it.
+func (w *Watcher) drain() []Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	batch := w.pending
+	w.pending = nil
+	clear(w.pendingIdx)
+
+	return batch
+}
+
+// Watched reports whether the event matches this watcher's root, filetypes, files, dirs, or globs.
 // Events with nil Info are always considered watched (e.g. manual triggers).
 func (w *Watcher) Watched(event Event) bool {
 	if event.Info() == nil {
@@ -104,6 +332,10 @@ func (w *Watcher) Watched(event Event) bool {
 		return true
 	}
 
+	if matchGlobs(w.globs, filepath.ToSlash(rel)) {
+		return true
+	}
+
 	for dir := range w.dirs {
 		if components.IsRelative(dir, rel) {
 			return true
@@ -113,9 +345,21 @@ func (w *Watcher) Watched(event Event) bool {
 	return false
 }
 
+// Close cancels any pending debounced change and releases the watcher's name, so that a new
+// Watcher may be registered under it. Unsubscribe the watcher from its EventEmitter first.
+func (w *Watcher) Close() {
+	w.debouncer.Stop()
+
+	watcherRegistryMu.Lock()
+	defer watcherRegistryMu.Unlock()
+	delete(watcherRegistry, w.name)
+}
+
 // Trigger manually invokes the onChange handler with an empty event, bypassing filters and debounce.
+// If an onBatch handler is set, it is invoked with an empty batch instead. As after a change, the
+// browser is then refreshed once the readiness probes pass, if a Proxy is configured.
 func (w *Watcher) Trigger() {
-	w.onChange(Event{})
+	w.fire(nil)
 }
 
 // WithFiletypes adds file extensions to watch (e.g. ".go", ".html").
@@ -143,12 +387,37 @@ func (w *Watcher) WithFiles(files ...string) *Watcher {
 	return w
 }
 
+// WithGlobs adds doublestar glob patterns to watch, matched against paths relative to the
+// watcher root, e.g. "**/*.templ" or "web/**/*.{js,css}". Panics if a pattern is invalid.
+func (w *Watcher) WithGlobs(globs ...string) *Watcher {
+	w.globs = append(w.globs, mustValidateGlobs(globs)...)
+	return w
+}
+
 // WithOnChange sets the handler called when a matching event is received.
 func (w *Watcher) WithOnChange(fn func(Event)) *Watcher {
 	w.onChange = fn
 	return w
 }
 
+// WithOnBatch sets a handler called with every event received during the debounce window,
+// de-duplicated by path with their operations merged. When set, it is called instead of onChange.
+func (w *Watcher) WithOnBatch(fn func([]Event)) *Watcher {
+	w.onBatch = func(batch []Event) bool {
+		fn(batch)
+		return true
+	}
+	return w
+}
+
+// WithOnBatchResult is WithOnBatch for a handler that reports whether it started the services
+// the readiness probes watch. When it returns false, e.g. because a build failed, the refresh that
+// follows does not wait for the probes, which would otherwise block until the readiness timeout.
+func (w *Watcher) WithOnBatchResult(fn func([]Event) bool) *Watcher {
+	w.onBatch = fn
+	return w
+}
+
 // WithProxy configures a Proxy to trigger a browser refresh after each onChange call.
 // refreshDelayMs is the delay in milliseconds to wait before refreshing. A nil proxy is a no-op.
 func (w *Watcher) WithProxy(proxy Proxy, refreshDelayMs uint) *Watcher {
@@ -161,12 +430,42 @@ func (w *Watcher) WithProxy(proxy Proxy, refreshDelayMs uint) *Watcher {
 	return w
 }
 
+// WithCSSRefresh sets file extensions (e.g. ".css") that are hot swapped in the browser rather
+// than reloading the page, when every change in a batch has one of them. Requires a Proxy that
+// implements StylesheetProxy; otherwise the page is reloaded as usual.
+func (w *Watcher) WithCSSRefresh(filetypes ...string) *Watcher {
+	for _, ftype := range filetypes {
+		w.cssFiletypes[ftype] = struct{}{}
+	}
+	return w
+}
+
+// WithReadiness gates each browser refresh on every probe reporting ready, waiting at most
+// timeoutMs milliseconds before refreshing anyway. Only applies when a Proxy is configured.
+func (w *Watcher) WithReadiness(timeoutMs uint, probes ...Probe) *Watcher {
+	w.probes = append(w.probes, probes...)
+	w.probeTimeout = time.Duration(timeoutMs) * time.Millisecond
+	return w
+}
+
 // WithDebounceDelay overrides the default debounce delay (DefaultDebounceDelay) in milliseconds.
 func (w *Watcher) WithDebounceDelay(delayMs uint) *Watcher {
 	w.debouncer.UpdateDelay(delayMs)
 	return w
 }
 
+// WithIgnoreOwnChanges breaks loops where the onChange (or onBatch) handler writes a watched file
+// that triggers the watcher again. Paths changed while the handler runs, or within graceMs
+// milliseconds after it returns, are tracked; a path that re-triggers the watcher from one run to
+// the next is logged, and its changes while the handler runs are ignored from then on. Changes to
+// other paths, and changes made between runs, always trigger. graceMs covers backends that report
+// changes late, e.g. a polling interval.
+func (w *Watcher) WithIgnoreOwnChanges(graceMs uint) *Watcher {
+	w.ignoreOwn = true
+	w.ownGrace = time.Duration(graceMs) * time.Millisecond
+	return w
+}
+
 // WithExcluder attaches an Excluder that filters out events before they reach the onChange handler.
 func (w *Watcher) WithExcluder(excluder *Excluder) *Watcher {
 	w.excluder = excluder
@@ -1,6 +1,7 @@
 package ev_test
 
 import (
+	"io/fs"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -110,6 +111,24 @@ func TestWatcher_Watched(t *testing.T) {
 			event:    fileEvent("other/main.go", ev.WRITE),
 			expected: false,
 		},
+		{
+			name:     "glob match at any depth",
+			setup:    func(w *ev.Watcher) *ev.Watcher { return w.WithGlobs("**/*.templ") },
+			event:    fileEvent("views/pages/home.templ", ev.WRITE),
+			expected: true,
+		},
+		{
+			name:     "glob match with alternatives",
+			setup:    func(w *ev.Watcher) *ev.Watcher { return w.WithGlobs("web/**/*.{js,css}") },
+			event:    fileEvent("web/static/app.css", ev.WRITE),
+			expected: true,
+		},
+		{
+			name:     "glob mismatch",
+			setup:    func(w *ev.Watcher) *ev.Watcher { return w.WithGlobs("web/**/*.{js,css}") },
+			event:    fileEvent("static/app.css", ev.WRITE),
+			expected: false,
+		},
 		{
 			name:     "no filetype file or dir configured",
 			setup:    func(w *ev.Watcher) *ev.Watcher { return w },
@@ -207,6 +226,106 @@ func TestWatcher_Handle(t *testing.T) {
 	}
 }
 
+func TestWatcher_Handle_Batch(t *testing.T) {
+	tests := []struct {
+		name          string
+		events        []ev.Event
+		expectedPaths []string
+		expectedOps   []ev.Op
+	}{
+		{
+			name:          "single event",
+			events:        []ev.Event{fileEvent("main.go", ev.WRITE)},
+			expectedPaths: []string{testRoot + "/main.go"},
+			expectedOps:   []ev.Op{ev.WRITE},
+		},
+		{
+			name: "distinct paths kept in order",
+			events: []ev.Event{
+				fileEvent("b.go", ev.CREATE),
+				fileEvent("a.go", ev.WRITE),
+			},
+			expectedPaths: []string{testRoot + "/b.go", testRoot + "/a.go"},
+			expectedOps:   []ev.Op{ev.CREATE, ev.WRITE},
+		},
+		{
+			name: "duplicate paths merged",
+			events: []ev.Event{
+				fileEvent("main.go", ev.CREATE),
+				fileEvent("other.go", ev.WRITE),
+				fileEvent("main.go", ev.WRITE),
+				fileEvent("main.go", ev.WRITE),
+			},
+			expectedPaths: []string{testRoot + "/main.go", testRoot + "/other.go"},
+			expectedOps:   []ev.Op{ev.CREATE | ev.WRITE, ev.WRITE},
+		},
+		{
+			name: "unwatched events not batched",
+			events: []ev.Event{
+				fileEvent("main.go", ev.WRITE),
+				fileEvent("styles.css", ev.WRITE),
+			},
+			expectedPaths: []string{testRoot + "/main.go"},
+			expectedOps:   []ev.Op{ev.WRITE},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ch := make(chan []ev.Event, 4)
+			w := ev.NewWatcher(t.Name(), testRoot).
+				WithFiletypes(".go").
+				WithDebounceDelay(debounceDelay).
+				WithOnBatch(func(batch []ev.Event) { ch <- batch })
+
+			for _, event := range test.events {
+				w.Handle(event)
+			}
+
+			time.Sleep(debounceWait)
+
+			if len(ch) != 1 {
+				t.Fatalf("onBatch fired %d time(s), expected 1", len(ch))
+			}
+
+			batch := <-ch
+			if len(batch) != len(test.expectedPaths) {
+				t.Fatalf("batch has %d event(s), expected %d", len(batch), len(test.expectedPaths))
+			}
+
+			for i, event := range batch {
+				if event.Path() != test.expectedPaths[i] {
+					t.Errorf("batch[%d].Path() = %q, expected %q", i, event.Path(), test.expectedPaths[i])
+				}
+				if event.Op() != test.expectedOps[i] {
+					t.Errorf("batch[%d].Op() = %v, expected %v", i, event.Op(), test.expectedOps[i])
+				}
+			}
+		})
+	}
+
+	t.Run("batch resets between windows", func(t *testing.T) {
+		ch := make(chan []ev.Event, 4)
+		w := ev.NewWatcher(t.Name(), testRoot).
+			WithFiletypes(".go").
+			WithDebounceDelay(debounceDelay).
+			WithOnBatch(func(batch []ev.Event) { ch <- batch })
+
+		w.Handle(fileEvent("a.go", ev.WRITE))
+		time.Sleep(debounceWait)
+		w.Handle(fileEvent("b.go", ev.WRITE))
+		time.Sleep(debounceWait)
+
+		if len(ch) != 2 {
+			t.Fatalf("onBatch fired %d time(s), expected 2", len(ch))
+		}
+		<-ch
+		if batch := <-ch; len(batch) != 1 || batch[0].Path() != testRoot+"/b.go" {
+			t.Errorf("second batch = %v, expected only b.go", batch)
+		}
+	})
+}
+
 func TestWatcher_Trigger(t *testing.T) {
 	var called atomic.Bool
 	w := ev.NewWatcher(t.Name(), ".")
@@ -217,6 +336,191 @@ func TestWatcher_Trigger(t *testing.T) {
 	if !called.Load() {
 		t.Error("Trigger() did not call onChange")
 	}
+
+	t.Run("with onBatch", func(t *testing.T) {
+		var called atomic.Bool
+		w := ev.NewWatcher(t.Name(), ".")
+		w.WithOnBatch(func(batch []ev.Event) { called.Store(batch == nil) })
+
+		w.Trigger()
+
+		if !called.Load() {
+			t.Error("Trigger() did not call onBatch with an empty batch")
+		}
+	})
+
+	t.Run("refreshes browser", func(t *testing.T) {
+		proxy := mockStylesheetProxy{refreshes: make(chan string, 1)}
+		w := ev.NewWatcher(t.Name(), ".").
+			WithOnBatch(func(_ []ev.Event) {}).
+			WithProxy(proxy, 0)
+
+		w.Trigger()
+
+		select {
+		case got := <-proxy.refreshes:
+			if got != "reload" {
+				t.Errorf("Trigger() refreshed %q, expected reload", got)
+			}
+		default:
+			t.Error("Trigger() did not refresh the browser")
+		}
+	})
+}
+
+func TestWatcher_WithIgnoreOwnChanges(t *testing.T) {
+	// newLoopingWatcher returns a watcher whose handler writes a generated file on each run, which
+	// would trigger the next run; runs stop after 5 regardless. edit is called during the second
+	// run, in which the loop is detected.
+	newLoopingWatcher := func(t *testing.T, ignore bool, runs *atomic.Int32, edit func(*ev.Watcher)) *ev.Watcher {
+		w := ev.NewWatcher(t.Name(), testRoot).
+			WithFiletypes(".go").
+			WithDebounceDelay(debounceDelay)
+		if ignore {
+			w.WithIgnoreOwnChanges(debounceDelay)
+		}
+
+		w.WithOnBatch(func(_ []ev.Event) {
+			n := runs.Add(1)
+			if n == 2 && edit != nil {
+				edit(w)
+			}
+			if n < 5 {
+				w.Handle(fileEvent("page_templ.go", ev.WRITE))
+			}
+		})
+		return w
+	}
+
+	tests := []struct {
+		name     string
+		ignore   bool
+		edit     func(*ev.Watcher)
+		expected int32
+	}{
+		{
+			name:     "own changes re-trigger by default",
+			expected: 5,
+		},
+		{
+			name:     "loop broken once detected",
+			ignore:   true,
+			expected: 2,
+		},
+		{
+			name:     "edit during run still triggers",
+			ignore:   true,
+			edit:     func(w *ev.Watcher) { w.Handle(fileEvent("main.go", ev.WRITE)) },
+			expected: 3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var runs atomic.Int32
+			w := newLoopingWatcher(t, test.ignore, &runs, test.edit)
+
+			w.Handle(fileEvent("main.go", ev.WRITE))
+			time.Sleep(8 * debounceWait)
+
+			if got := runs.Load(); got != test.expected {
+				t.Errorf("onBatch fired %d time(s), expected %d", got, test.expected)
+			}
+		})
+	}
+
+	t.Run("known loop ignored on later runs", func(t *testing.T) {
+		var runs atomic.Int32
+		w := newLoopingWatcher(t, true, &runs, nil)
+
+		w.Handle(fileEvent("main.go", ev.WRITE))
+		time.Sleep(4 * debounceWait)
+		w.Handle(fileEvent("main.go", ev.WRITE))
+		time.Sleep(4 * debounceWait)
+
+		if got := runs.Load(); got != 3 {
+			t.Errorf("onBatch fired %d time(s), expected 3", got)
+		}
+	})
+
+	t.Run("own path changed between runs triggers", func(t *testing.T) {
+		var runs atomic.Int32
+		w := newLoopingWatcher(t, true, &runs, nil)
+
+		w.Handle(fileEvent("main.go", ev.WRITE))
+		time.Sleep(4 * debounceWait)
+		w.Handle(ev.NewEvent(ev.WRITE, testRoot+"/page_templ.go", mockFileInfoAt{"page_templ.go", time.Now()}))
+		time.Sleep(4 * debounceWait)
+
+		if got := runs.Load(); got != 3 {
+			t.Errorf("onBatch fired %d time(s), expected 3", got)
+		}
+	})
+}
+
+type mockFileInfoAt struct {
+	name    string
+	modTime time.Time
+}
+
+func (m mockFileInfoAt) Name() string       { return m.name }
+func (m mockFileInfoAt) Size() int64        { return 0 }
+func (m mockFileInfoAt) Mode() fs.FileMode  { return 0 }
+func (m mockFileInfoAt) ModTime() time.Time { return m.modTime }
+func (m mockFileInfoAt) IsDir() bool        { return false }
+func (m mockFileInfoAt) Sys() any           { return nil }
+
+func TestWatcher_Close(t *testing.T) {
+	w := ev.NewWatcher(t.Name(), ".")
+	w.Close()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("NewWatcher() panicked after Close() released the name: %v", r)
+		}
+	}()
+	ev.NewWatcher(t.Name(), ".")
+}
+
+func TestWatcher_WithOnBatchResult(t *testing.T) {
+	const readinessTimeout = 300 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		started bool
+		awaited bool
+	}{
+		{name: "services started awaits readiness", started: true, awaited: true},
+		{name: "services not started skips readiness", started: false, awaited: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			probe, err := ev.NewLogProbe("never logged")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			proxy := mockStylesheetProxy{refreshes: make(chan string, 1)}
+			w := ev.NewWatcher(t.Name(), ".").
+				WithOnBatchResult(func(_ []ev.Event) bool { return test.started }).
+				WithReadiness(uint(readinessTimeout.Milliseconds()), probe).
+				WithProxy(proxy, 0)
+
+			start := time.Now()
+			w.Trigger()
+
+			if waited := time.Since(start) >= readinessTimeout; waited != test.awaited {
+				t.Errorf("Trigger() awaited readiness = %v, expected %v", waited, test.awaited)
+			}
+
+			select {
+			case <-proxy.refreshes:
+			default:
+				t.Error("Trigger() did not refresh the browser")
+			}
+		})
+	}
 }
 
 func TestWatcher_Builders_Chainable(t *testing.T) {
@@ -228,9 +532,15 @@ func TestWatcher_Builders_Chainable(t *testing.T) {
 		{"WithFiletypes", w.WithFiletypes(".go")},
 		{"WithDirs", w.WithDirs("src")},
 		{"WithFiles", w.WithFiles("main.go")},
+		{"WithGlobs", w.WithGlobs("**/*.go")},
 		{"WithOnChange", w.WithOnChange(func(_ ev.Event) {})},
+		{"WithOnBatch", w.WithOnBatch(func(_ []ev.Event) {})},
+		{"WithOnBatchResult", w.WithOnBatchResult(func(_ []ev.Event) bool { return true })},
 		{"WithDebounceDelay", w.WithDebounceDelay(50)},
+		{"WithReadiness", w.WithReadiness(50)},
+		{"WithCSSRefresh", w.WithCSSRefresh(".css")},
 		{"WithExcluder", w.WithExcluder(ev.NewExcluder("."))},
+		{"WithIgnoreOwnChanges", w.WithIgnoreOwnChanges(50)},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -240,3 +550,79 @@ func TestWatcher_Builders_Chainable(t *testing.T) {
 		})
 	}
 }
+
+type mockStylesheetProxy struct{ refreshes chan string }
+
+func (m mockStylesheetProxy) RefreshBrowser()        { m.refreshes <- "reload" }
+func (m mockStylesheetProxy) RefreshCSS(path string) { m.refreshes <- path }
+
+func TestWatcher_WithCSSRefresh(t *testing.T) {
+	tests := []struct {
+		name         string
+		cssFiletypes []string
+		events       []ev.Event
+		expected     []string
+	}{
+		{
+			name:         "stylesheet change is hot swapped",
+			cssFiletypes: []string{".css"},
+			events:       []ev.Event{fileEvent("app.css", ev.WRITE)},
+			expected:     []string{testRoot + "/app.css"},
+		},
+		{
+			name:         "every changed stylesheet is hot swapped",
+			cssFiletypes: []string{".css"},
+			events:       []ev.Event{fileEvent("app.css", ev.WRITE), fileEvent("theme.css", ev.CREATE)},
+			expected:     []string{testRoot + "/app.css", testRoot + "/theme.css"},
+		},
+		{
+			name:         "mixed batch reloads",
+			cssFiletypes: []string{".css"},
+			events:       []ev.Event{fileEvent("app.css", ev.WRITE), fileEvent("index.html", ev.WRITE)},
+			expected:     []string{"reload"},
+		},
+		{
+			name:         "removed stylesheet reloads",
+			cssFiletypes: []string{".css"},
+			events:       []ev.Event{fileEvent("app.css", ev.REMOVE)},
+			expected:     []string{"reload"},
+		},
+		{
+			name:     "no css filetypes reloads",
+			events:   []ev.Event{fileEvent("app.css", ev.WRITE)},
+			expected: []string{"reload"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			proxy := mockStylesheetProxy{refreshes: make(chan string, 10)}
+			w := ev.NewWatcher(t.Name(), testRoot).
+				WithFiletypes(".css", ".html").
+				WithDebounceDelay(debounceDelay).
+				WithProxy(proxy, 0).
+				WithCSSRefresh(test.cssFiletypes...).
+				WithOnBatch(func(_ []ev.Event) {})
+
+			for _, event := range test.events {
+				w.Handle(event)
+			}
+
+			for _, expected := range test.expected {
+				select {
+				case got := <-proxy.refreshes:
+					if got != expected {
+						t.Errorf("refresh = %q, expected %q", got, expected)
+					}
+				case <-time.After(eventTimeout):
+					t.Fatalf("timed out waiting for refresh %q", expected)
+				}
+			}
+
+			time.Sleep(debounceWait)
+			if len(proxy.refreshes) != 0 {
+				t.Errorf("got %d unexpected refresh(es)", len(proxy.refreshes))
+			}
+		})
+	}
+}